@@ -29,6 +29,36 @@ resource "lambdalabs_instance" "example_instance" {
   region           = "us-west-1"
   ssh_key_names    = [lambdalabs_ssh_key.instance_ssh_key.name]
   filesystem_names = ["stable-diffusion"]
+
+  # Wait up to 2 hours for capacity to free up
+  capacity_timeout = "2h"
+
+  # Bump to restart the instance in place, e.g. to recover wedged GPU drivers
+  restart_triggers = {
+    drivers = "1"
+  }
+
+  timeouts {
+    create = "3h"
+  }
+}
+
+# Launch several identical nodes at once. Filesystems cannot be shared between them.
+resource "lambdalabs_instance" "training_nodes" {
+  instance_type = "gpu_1x_a10"
+  region        = "us-west-1"
+  ssh_key_names = [lambdalabs_ssh_key.instance_ssh_key.name]
+  quantity      = 2
+}
+
+# Launch the first instance type and region with available capacity
+resource "lambdalabs_instance" "fallback" {
+  ssh_key_names = [lambdalabs_ssh_key.instance_ssh_key.name]
+
+  candidates = [
+    { instance_type = "gpu_1x_h100_pcie", region = "us-east-1" },
+    { instance_type = "gpu_1x_a100", region = "us-west-2" },
+  ]
 }
 
 data "lambdalabs_instance" "example" {
@@ -41,8 +71,13 @@ data "lambdalabs_instances" "all_instances" {
   depends_on = [lambdalabs_instance.example_instance]
 }
 
+output "lambdalabs_instance_ip" {
+  value = lambdalabs_instance.example_instance.ip
+}
+
 output "lambdalabs_instance_result" {
-  value = lambdalabs_instance.example_instance
+  value     = lambdalabs_instance.example_instance
+  sensitive = true
 }
 
 
@@ -4,4 +4,9 @@ variable "lambdalabs_api_key" {
 
 provider "lambdalabs" {
   api_key = var.lambdalabs_api_key
+
+  retry {
+    max_attempts = 5
+    max_backoff  = "30s"
+  }
 }
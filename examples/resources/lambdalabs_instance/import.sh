@@ -0,0 +1,5 @@
+# Import an instance by its ID
+terraform import lambdalabs_instance.example 0920582c7ff041399e34823a0be62549
+
+# Import an instance by its name
+terraform import lambdalabs_instance.example name:training-node-1
@@ -0,0 +1,5 @@
+# Import an SSH key by its ID
+terraform import lambdalabs_ssh_key.example ddf9a910ceb744a0bb95242cbba6cb50
+
+# Import an SSH key by its name
+terraform import lambdalabs_ssh_key.example name:laptop
@@ -0,0 +1,10 @@
+# Keep the SSH keys of the account in sync with the reviewed keys, and delete any other key
+# that no instance uses
+resource "lambdalabs_ssh_key_set" "example" {
+  exclusive = true
+
+  keys = {
+    laptop = file("~/.ssh/id_ed25519.pub")
+    ci     = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIPnC44uo7OUyVK7yKRnzsEbVJbYgv8SZqVYxS/LKxgiu ci"
+  }
+}
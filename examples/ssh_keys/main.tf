@@ -20,6 +20,12 @@ resource "lambdalabs_ssh_key" "edu" {
   public_key = trimspace(file("~/.ssh/id_ed25519.pub"))
 }
 
+# Let Lambda Labs generate a throwaway key pair, and write its private key to a file
+resource "lambdalabs_ssh_key" "generated" {
+  name             = "example-generated-key"
+  private_key_file = "${path.module}/example-generated-key.pem"
+}
+
 # List Just One SSH Key by Name
 data "lambdalabs_ssh_key" "edu" {
   depends_on = [lambdalabs_ssh_key.edu]
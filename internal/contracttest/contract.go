@@ -0,0 +1,230 @@
+// Package contracttest checks API calls against the OpenAPI specification of the Lambda Cloud
+// API in tests, so that the fake API and recorded responses do not drift from it. It is kept
+// out of pgk/lambdalabs so that the provider binary does not link kin-openapi.
+package contracttest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// ContractError is returned by a contract validating doer when a request or a response does not
+// conform to the API specification.
+type ContractError struct {
+	// Operation ID of the operation in the specification, empty if the request matched none
+	Operation string
+
+	// Method HTTP method of the request
+	Method string
+
+	// URL Path and query of the request
+	URL string
+
+	// StatusCode HTTP status code of the response, zero if the request is invalid
+	StatusCode int
+
+	// Pointer JSON pointer to the invalid value in the body, e.g. /quantity, if a schema was violated
+	Pointer string
+
+	// Keyword Schema keyword that was violated, e.g. maxLength
+	Keyword string
+
+	// Err Validation error returned by kin-openapi
+	Err error
+}
+
+func (e *ContractError) Error() string {
+	var b strings.Builder
+	if e.Operation != "" {
+		fmt.Fprintf(&b, "%s ", e.Operation)
+	}
+	if e.StatusCode == 0 {
+		fmt.Fprintf(&b, "request %s %s", e.Method, e.URL)
+	} else {
+		fmt.Fprintf(&b, "response %d to %s %s", e.StatusCode, e.Method, e.URL)
+	}
+	b.WriteString(" does not conform to the API specification")
+	if e.Keyword != "" {
+		pointer := e.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		fmt.Fprintf(&b, " at %s (%s)", pointer, e.Keyword)
+	}
+	fmt.Fprintf(&b, ": %s", e.Err)
+	return b.String()
+}
+
+func (e *ContractError) Unwrap() error {
+	return e.Err
+}
+
+// WithContractValidation checks every request and response against spec, the contents of
+// openapi.yaml, and fails the call with a *ContractError if either does not conform to it.
+// It is meant for tests against fake or recorded responses.
+//
+// It wraps the HttpRequestDoer configured so far, so it must come right after WithHTTPClient
+// in order to see every request as it is sent.
+func WithContractValidation(spec []byte) lambdalabs.ClientOption {
+	return func(c *lambdalabs.Client) error {
+		doer, err := NewContractValidatingDoer(spec, c.Client)
+		if err != nil {
+			return err
+		}
+		c.Client = doer
+		return nil
+	}
+}
+
+// NewContractValidatingDoer wraps doer, or a default http.Client if doer is nil, to validate
+// requests and responses as described by WithContractValidation.
+func NewContractValidatingDoer(spec []byte, doer lambdalabs.HttpRequestDoer) (lambdalabs.HttpRequestDoer, error) {
+	if doer == nil {
+		doer = &http.Client{}
+	}
+
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load API specification: %w", err)
+	}
+	// Examples are not validated, the ones in openapi.yaml have prices as strings. They are
+	// documentation only and never sent or received.
+	validationOpts := []openapi3.ValidationOption{openapi3.DisableExamplesValidation()}
+	if err := doc.Validate(loader.Context, validationOpts...); err != nil {
+		return nil, fmt.Errorf("invalid API specification: %w", err)
+	}
+
+	// Requests are routed by their path below the base path of a server, so that they match
+	// regardless of the host they are sent to, e.g. a fake API on localhost.
+	var basePaths []string
+	for _, server := range doc.Servers {
+		serverURL, err := url.Parse(server.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server URL %q in API specification: %w", server.URL, err)
+		}
+		basePaths = append(basePaths, strings.TrimSuffix(serverURL.Path, "/"))
+	}
+	doc.Servers = nil
+
+	router, err := legacy.NewRouter(doc, validationOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to route API specification: %w", err)
+	}
+	return &contractValidatingDoer{
+		doer:      doer,
+		router:    router,
+		basePaths: basePaths,
+	}, nil
+}
+
+type contractValidatingDoer struct {
+	doer      lambdalabs.HttpRequestDoer
+	router    routers.Router
+	basePaths []string
+}
+
+func (d *contractValidatingDoer) Do(req *http.Request) (*http.Response, error) {
+	contractErr := &ContractError{
+		Method: req.Method,
+		URL:    req.URL.RequestURI(),
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	route, pathParams, err := d.router.FindRoute(d.routedRequest(req))
+	if err != nil {
+		contractErr.Err = err
+		return nil, contractErr
+	}
+	contractErr.Operation = route.Operation.OperationID
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		SkipSettingDefaults: true,
+	}
+	validatedReq := req.Clone(req.Context())
+	validatedReq.Body = io.NopCloser(bytes.NewReader(body))
+	requestInput := &openapi3filter.RequestValidationInput{
+		Request:    validatedReq,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    options,
+	}
+	if err := openapi3filter.ValidateRequest(req.Context(), requestInput); err != nil {
+		return nil, contractErr.with(err)
+	}
+
+	resp, err := d.doer.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: requestInput,
+		Status:                 resp.StatusCode,
+		Header:                 resp.Header,
+		Body:                   io.NopCloser(bytes.NewReader(data)),
+		Options:                options,
+	}
+	if err := openapi3filter.ValidateResponse(context.Background(), responseInput); err != nil {
+		contractErr.StatusCode = resp.StatusCode
+		return nil, contractErr.with(err)
+	}
+	return resp, nil
+}
+
+// routedRequest returns req with the base path of the server it is sent to removed.
+func (d *contractValidatingDoer) routedRequest(req *http.Request) *http.Request {
+	for _, basePath := range d.basePaths {
+		if basePath == "" || !strings.HasPrefix(req.URL.Path, basePath+"/") {
+			continue
+		}
+		routed := req.Clone(req.Context())
+		routed.URL.Path = strings.TrimPrefix(req.URL.Path, basePath)
+		routed.URL.RawPath = ""
+		return routed
+	}
+	return req
+}
+
+// with sets the validation error, and the invalid value and schema keyword if a schema was
+// violated.
+func (e *ContractError) with(err error) *ContractError {
+	e.Err = err
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		e.Keyword = schemaErr.SchemaField
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			e.Pointer = "/" + strings.Join(pointer, "/")
+		}
+	}
+	return e
+}
@@ -0,0 +1,140 @@
+package contracttest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"testing"
+)
+
+func TestContractValidation(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/ssh-keys":
+			_, _ = io.WriteString(w, `{"data": [{"id": "1", "name": "laptop", "public_key": "ssh-rsa AAAA"}]}`)
+		case "GET /api/v1/file-systems":
+			_, _ = io.WriteString(w, `{"data": [{"id": "1", "name": "shared", "created": "2023-01-01T00:00:00Z", "is_in_use": false}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	services := newContractTestServices(t, server.URL+"/api/v1")
+	ctx := context.Background()
+
+	if _, err := services.SSHKeys.List(ctx); err != nil {
+		t.Fatalf("expected a conforming request and response to pass, got %s", err)
+	}
+
+	tests := map[string]struct {
+		call       func() error
+		operation  string
+		statusCode int
+		pointer    string
+		keyword    string
+	}{
+		"quantity above maximum": {
+			call: func() error {
+				quantity := 2
+				_, err := services.Instances.Launch(ctx, lambdalabs.LaunchInstanceJSONRequestBody{
+					InstanceTypeName: "gpu_1x_a10",
+					RegionName:       "us-east-1",
+					SshKeyNames:      []lambdalabs.SshKeyName{"laptop"},
+					Quantity:         &quantity,
+				})
+				return err
+			},
+			operation: "launchInstance",
+			pointer:   "/quantity",
+			keyword:   "maximum",
+		},
+		"name above maxLength": {
+			call: func() error {
+				_, err := services.SSHKeys.Add(ctx, lambdalabs.AddSSHKeyJSONRequestBody{Name: strings.Repeat("a", 65)})
+				return err
+			},
+			operation: "addSSHKey",
+			pointer:   "/name",
+			keyword:   "maxLength",
+		},
+		"response missing required property": {
+			call: func() error {
+				_, err := services.FileSystems.List(ctx)
+				return err
+			},
+			operation:  "listFileSystems",
+			statusCode: http.StatusOK,
+			pointer:    "/data/0/created_by",
+			keyword:    "required",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			before := atomic.LoadInt32(&calls)
+			err := test.call()
+			var contractErr *ContractError
+			if !errors.As(err, &contractErr) {
+				t.Fatalf("expected a contract error, got %v", err)
+			}
+			if contractErr.Operation != test.operation || contractErr.StatusCode != test.statusCode ||
+				contractErr.Pointer != test.pointer || contractErr.Keyword != test.keyword {
+				t.Errorf("unexpected contract error %#v", contractErr)
+			}
+			if !strings.Contains(err.Error(), test.pointer+" ("+test.keyword+")") {
+				t.Errorf("expected the error to point at the violated schema, got %s", err)
+			}
+			if test.statusCode == 0 && atomic.LoadInt32(&calls) != before {
+				t.Errorf("expected an invalid request not to be sent")
+			}
+		})
+	}
+}
+
+func TestContractValidationUnknownOperation(t *testing.T) {
+	doer, err := NewContractValidatingDoer(readContractTestSpec(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://unreachable.invalid/api/v1/unknown", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contractErr *ContractError
+	if _, err := doer.Do(req); !errors.As(err, &contractErr) || contractErr.Operation != "" {
+		t.Errorf("expected a contract error for an operation missing from the specification, got %v", err)
+	}
+}
+
+func TestContractValidationInvalidSpec(t *testing.T) {
+	if _, err := NewContractValidatingDoer([]byte("openapi: 3.0.0\npaths: []\n"), nil); err == nil {
+		t.Errorf("expected an error for an invalid specification")
+	}
+}
+
+func readContractTestSpec(t *testing.T) []byte {
+	t.Helper()
+	spec, err := os.ReadFile("../../openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func newContractTestServices(t *testing.T, host string) *lambdalabs.Services {
+	t.Helper()
+	client, err := lambdalabs.NewAuthenticatedClient(host, "api-key", WithContractValidation(readContractTestSpec(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lambdalabs.NewServices(client)
+}
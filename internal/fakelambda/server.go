@@ -0,0 +1,777 @@
+// Package fakelambda implements a stateful fake of the Lambda Cloud API described by openapi.yaml,
+// so that the provider can be tested end to end without credentials.
+package fakelambda
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+)
+
+// APIKey is the only API key accepted by the Server.
+const APIKey = "fake-lambda-api-key"
+
+// Operation IDs of the API, as used in openapi.yaml.
+const (
+	OperationInstanceTypes     = "instanceTypes"
+	OperationListInstances     = "listInstances"
+	OperationGetInstance       = "getInstance"
+	OperationLaunchInstance    = "launchInstance"
+	OperationTerminateInstance = "terminateInstance"
+	OperationRestartInstance   = "restartInstance"
+	OperationListSSHKeys       = "listSSHKeys"
+	OperationAddSSHKey         = "addSSHKey"
+	OperationDeleteSSHKey      = "deleteSSHKey"
+	OperationListFileSystems   = "listFileSystems"
+)
+
+// removed is queued after terminated for instances that should disappear from the API.
+const removed lambdalabs.InstanceStatus = ""
+
+// Server is a fake Lambda Cloud API served over HTTP.
+//
+// Instances move from booting to active and from terminating to terminated, and are then
+// removed, a step at a time each time they are returned by the API. Capacity is set per
+// instance type and region and used up by launches. Any error code can be injected into
+// the next calls of an operation.
+type Server struct {
+	// URL Base URL of the API, to use as the provider host
+	URL string
+
+	// BootPolls Number of times an instance is returned as booting after a launch or a restart,
+	// before it is active. Set it before the first request.
+	BootPolls int
+
+	// TerminatePolls Number of times an instance is returned as terminating, before it is
+	// returned once as terminated and removed. Set it before the first request.
+	TerminatePolls int
+
+	server *httptest.Server
+
+	mu            sync.Mutex
+	regions       map[string]lambdalabs.Region
+	instanceTypes map[string]lambdalabs.InstanceType
+	capacity      map[string]map[string]int
+	instances     map[string]*instance
+	sshKeys       map[string]lambdalabs.SshKey
+	fileSystems   map[string]lambdalabs.FileSystem
+	errors        map[string][]injectedError
+	calls         map[string]int
+	sequence      int
+}
+
+type instance struct {
+	lambdalabs.Instance
+	sequence int
+	// pending Statuses the instance goes through the next times it is returned
+	pending []lambdalabs.InstanceStatus
+}
+
+type injectedError struct {
+	statusCode int
+	code       lambdalabs.ErrorCode
+}
+
+// NewServer starts a Server with a small catalog of regions and instance types, see
+// AddRegion, AddInstanceType and SetCapacity. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		BootPolls:      1,
+		TerminatePolls: 1,
+		regions:        map[string]lambdalabs.Region{},
+		instanceTypes:  map[string]lambdalabs.InstanceType{},
+		capacity:       map[string]map[string]int{},
+		instances:      map[string]*instance{},
+		sshKeys:        map[string]lambdalabs.SshKey{},
+		fileSystems:    map[string]lambdalabs.FileSystem{},
+		errors:         map[string][]injectedError{},
+		calls:          map[string]int{},
+	}
+
+	s.AddRegion("us-east-1", "Virginia, USA")
+	s.AddRegion("us-west-1", "California, USA")
+	s.AddInstanceType("gpu_1x_a10", "1x A10 (24 GB PCIe)", 60, 30, 200, 1400)
+	s.AddInstanceType("gpu_1x_a100", "1x A100 (40 GB SXM4)", 110, 30, 200, 512)
+	s.AddInstanceType("gpu_8x_a100_80gb_sxm4", "8x A100 (80 GB SXM4)", 1200, 240, 1800, 20480)
+	s.SetCapacity("gpu_1x_a10", "us-east-1", 10)
+	s.SetCapacity("gpu_1x_a10", "us-west-1", 10)
+	s.SetCapacity("gpu_1x_a100", "us-west-1", 10)
+
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL + "/api/v1"
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// AddRegion adds a region.
+func (s *Server) AddRegion(name, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.regions[name] = lambdalabs.Region{Name: name, Description: description}
+}
+
+// AddInstanceType adds an instance type, without capacity in any region.
+func (s *Server) AddInstanceType(name, description string, priceCentsPerHour, vcpus, memoryGib, storageGib int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	instanceType := lambdalabs.InstanceType{
+		Name:              name,
+		Description:       description,
+		PriceCentsPerHour: priceCentsPerHour,
+	}
+	instanceType.Specs.Vcpus = vcpus
+	instanceType.Specs.MemoryGib = memoryGib
+	instanceType.Specs.StorageGib = storageGib
+	s.instanceTypes[name] = instanceType
+}
+
+// SetCapacity sets the number of instances of a type that can still be launched in a region.
+func (s *Server) SetCapacity(instanceType, region string, capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.capacity[instanceType] == nil {
+		s.capacity[instanceType] = map[string]int{}
+	}
+	s.capacity[instanceType][region] = capacity
+}
+
+// AddFileSystem adds a file system to a region.
+func (s *Server) AddFileSystem(name, region string) lambdalabs.FileSystem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fileSystem := lambdalabs.FileSystem{
+		Id:      newID(),
+		Name:    name,
+		Created: time.Now().UTC().Format(time.RFC3339),
+		CreatedBy: lambdalabs.User{
+			Id:     "0920582c7ff041399e34823a0be62549",
+			Email:  "teammate@example.com",
+			Status: lambdalabs.UserStatusActive,
+		},
+		MountPoint: "/home/ubuntu/" + name,
+		Region:     s.regions[region],
+	}
+	s.fileSystems[fileSystem.Id] = fileSystem
+	return fileSystem
+}
+
+// AddSSHKey adds an SSH key, as if it was added outside of Terraform.
+func (s *Server) AddSSHKey(name, publicKey string) lambdalabs.SshKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sshKey := lambdalabs.SshKey{Id: newID(), Name: name, PublicKey: publicKey}
+	s.sshKeys[sshKey.Id] = sshKey
+	return sshKey
+}
+
+// DeleteSSHKey deletes an SSH key, as if it was deleted outside of Terraform.
+func (s *Server) DeleteSSHKey(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sshKeys, id)
+}
+
+// SetSSHKeyPublicKey changes the public key of an SSH key, which the API cannot do, to simulate
+// drift.
+func (s *Server) SetSSHKeyPublicKey(id, publicKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sshKey, ok := s.sshKeys[id]; ok {
+		sshKey.PublicKey = publicKey
+		s.sshKeys[id] = sshKey
+	}
+}
+
+// SSHKeys returns the SSH keys, sorted by name.
+func (s *Server) SSHKeys() []lambdalabs.SshKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedSSHKeys()
+}
+
+// Instance returns an instance as it currently is, without moving it to its next status.
+func (s *Server) Instance(id string) (lambdalabs.Instance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.instances[id]
+	if !ok {
+		return lambdalabs.Instance{}, false
+	}
+	return i.Instance, true
+}
+
+// Instances returns the instances in launch order, without moving them to their next status.
+func (s *Server) Instances() []lambdalabs.Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	instances := make([]lambdalabs.Instance, 0, len(s.instances))
+	for _, i := range s.sortedInstances() {
+		instances = append(instances, i.Instance)
+	}
+	return instances
+}
+
+// SetInstanceStatus sets the status of an instance, as if it changed outside of Terraform.
+// Passing terminated removes the instance the next time it is returned.
+func (s *Server) SetInstanceStatus(id string, status lambdalabs.InstanceStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.instances[id]
+	if !ok {
+		return
+	}
+	i.Status = status
+	i.pending = nil
+	if status == lambdalabs.InstanceStatusTerminated {
+		i.pending = []lambdalabs.InstanceStatus{lambdalabs.InstanceStatusTerminated, removed}
+	}
+}
+
+// InjectError makes the next `times` calls of an operation fail with code, and the HTTP
+// status the API uses for it.
+func (s *Server) InjectError(operationID string, code lambdalabs.ErrorCode, times int) {
+	s.InjectErrorStatus(operationID, statusCodes[code], code, times)
+}
+
+// InjectErrorStatus makes the next `times` calls of an operation fail with an HTTP status,
+// e.g. 429 or 503, and an error code.
+func (s *Server) InjectErrorStatus(operationID string, statusCode int, code lambdalabs.ErrorCode, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n := 0; n < times; n++ {
+		s.errors[operationID] = append(s.errors[operationID], injectedError{statusCode: statusCode, code: code})
+	}
+}
+
+// Calls returns the number of calls of an operation so far, including failed ones.
+func (s *Server) Calls(operationID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[operationID]
+}
+
+// statusCodes are the HTTP statuses the API responds with for each error code.
+var statusCodes = map[lambdalabs.ErrorCode]int{
+	lambdalabs.ErrorCodeUnknown:                 http.StatusInternalServerError,
+	lambdalabs.ErrorCodeInvalidAPIKey:           http.StatusUnauthorized,
+	lambdalabs.ErrorCodeAccountInactive:         http.StatusForbidden,
+	lambdalabs.ErrorCodeInvalidParameters:       http.StatusBadRequest,
+	lambdalabs.ErrorCodeObjectDoesNotExist:      http.StatusNotFound,
+	lambdalabs.ErrorCodeQuotaExceeded:           http.StatusBadRequest,
+	lambdalabs.ErrorCodeInsufficientCapacity:    http.StatusBadRequest,
+	lambdalabs.ErrorCodeFileSystemInWrongRegion: http.StatusBadRequest,
+	lambdalabs.ErrorCodeFileSystemsNotSupported: http.StatusBadRequest,
+	lambdalabs.ErrorCodeSSHKeyInUse:             http.StatusBadRequest,
+}
+
+// apiError is an error response of the API.
+type apiError struct {
+	statusCode  int
+	code        lambdalabs.ErrorCode
+	message     string
+	fieldErrors map[string]lambdalabs.Error
+}
+
+func newAPIError(code lambdalabs.ErrorCode, format string, a ...interface{}) *apiError {
+	return &apiError{statusCode: statusCodes[code], code: code, message: fmt.Sprintf(format, a...)}
+}
+
+func invalidParameter(field string, format string, a ...interface{}) *apiError {
+	message := fmt.Sprintf(format, a...)
+	return &apiError{
+		statusCode: http.StatusBadRequest,
+		code:       lambdalabs.ErrorCodeInvalidParameters,
+		message:    "Invalid request data.",
+		fieldErrors: map[string]lambdalabs.Error{
+			field: {Code: lambdalabs.ErrorCodeInvalidParameters, Message: message},
+		},
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	operationID, id := route(r.Method, strings.TrimPrefix(r.URL.Path, "/api/v1"))
+	if operationID == "" {
+		writeError(w, newAPIError(lambdalabs.ErrorCodeObjectDoesNotExist, "Unknown endpoint %s %s.", r.Method, r.URL.Path))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[operationID]++
+
+	if !authorized(r) {
+		writeError(w, newAPIError(lambdalabs.ErrorCodeInvalidAPIKey, "API key was invalid, expired, or deleted."))
+		return
+	}
+	if injected := s.errors[operationID]; len(injected) > 0 {
+		s.errors[operationID] = injected[1:]
+		writeError(w, &apiError{
+			statusCode: injected[0].statusCode,
+			code:       injected[0].code,
+			message:    fmt.Sprintf("Injected %s error.", injected[0].code),
+		})
+		return
+	}
+
+	var data interface{}
+	var err *apiError
+	switch operationID {
+	case OperationInstanceTypes:
+		data = s.listInstanceTypes()
+	case OperationListInstances:
+		data = s.listInstances()
+	case OperationGetInstance:
+		data, err = s.getInstance(id)
+	case OperationLaunchInstance:
+		data, err = s.launchInstance(r)
+	case OperationTerminateInstance:
+		data, err = s.terminateInstance(r)
+	case OperationRestartInstance:
+		data, err = s.restartInstance(r)
+	case OperationListSSHKeys:
+		data = s.sortedSSHKeys()
+	case OperationAddSSHKey:
+		data, err = s.addSSHKey(r)
+	case OperationDeleteSSHKey:
+		data, err = s.deleteSSHKey(id)
+	case OperationListFileSystems:
+		data = s.listFileSystems()
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+// route returns the operation ID of a request, and the ID in its path if any.
+func route(method, path string) (string, string) {
+	switch {
+	case method == http.MethodGet && path == "/instance-types":
+		return OperationInstanceTypes, ""
+	case method == http.MethodGet && path == "/instances":
+		return OperationListInstances, ""
+	case method == http.MethodGet && strings.HasPrefix(path, "/instances/"):
+		return OperationGetInstance, strings.TrimPrefix(path, "/instances/")
+	case method == http.MethodPost && path == "/instance-operations/launch":
+		return OperationLaunchInstance, ""
+	case method == http.MethodPost && path == "/instance-operations/terminate":
+		return OperationTerminateInstance, ""
+	case method == http.MethodPost && path == "/instance-operations/restart":
+		return OperationRestartInstance, ""
+	case method == http.MethodGet && path == "/ssh-keys":
+		return OperationListSSHKeys, ""
+	case method == http.MethodPost && path == "/ssh-keys":
+		return OperationAddSSHKey, ""
+	case method == http.MethodDelete && strings.HasPrefix(path, "/ssh-keys/"):
+		return OperationDeleteSSHKey, strings.TrimPrefix(path, "/ssh-keys/")
+	case method == http.MethodGet && path == "/file-systems":
+		return OperationListFileSystems, ""
+	}
+	return "", ""
+}
+
+// authorized accepts the API key as a bearer token, or as the basic auth user name with or
+// without base64 encoding.
+func authorized(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "Bearer "+APIKey || authorization == "Basic "+APIKey {
+		return true
+	}
+	user, _, ok := r.BasicAuth()
+	return ok && user == APIKey
+}
+
+func (s *Server) listInstanceTypes() map[string]lambdalabs.InstanceTypeAvailability {
+	instanceTypes := make(map[string]lambdalabs.InstanceTypeAvailability, len(s.instanceTypes))
+	for name, instanceType := range s.instanceTypes {
+		regions := make([]lambdalabs.Region, 0)
+		for region, capacity := range s.capacity[name] {
+			if capacity > 0 {
+				regions = append(regions, s.regions[region])
+			}
+		}
+		sort.Slice(regions, func(i, j int) bool { return regions[i].Name < regions[j].Name })
+		instanceTypes[name] = lambdalabs.InstanceTypeAvailability{
+			InstanceType:                 instanceType,
+			RegionsWithCapacityAvailable: regions,
+		}
+	}
+	return instanceTypes
+}
+
+func (s *Server) listInstances() []lambdalabs.Instance {
+	instances := make([]lambdalabs.Instance, 0, len(s.instances))
+	for _, i := range s.sortedInstances() {
+		if s.advance(i) {
+			instances = append(instances, i.Instance)
+		}
+	}
+	return instances
+}
+
+func (s *Server) getInstance(id string) (interface{}, *apiError) {
+	i, ok := s.instances[id]
+	if !ok || !s.advance(i) {
+		return nil, newAPIError(lambdalabs.ErrorCodeObjectDoesNotExist, "Instance %s does not exist.", id)
+	}
+	return i.Instance, nil
+}
+
+// advance moves an instance to its next status, and returns false if it was removed.
+func (s *Server) advance(i *instance) bool {
+	if len(i.pending) == 0 {
+		return true
+	}
+	status := i.pending[0]
+	i.pending = i.pending[1:]
+	if status == removed {
+		delete(s.instances, i.Id)
+		return false
+	}
+	i.Status = status
+	return true
+}
+
+func (s *Server) launchInstance(r *http.Request) (interface{}, *apiError) {
+	var body lambdalabs.LaunchInstanceJSONRequestBody
+	if err := decodeBody(r, &body); err != nil {
+		return nil, err
+	}
+
+	instanceType, ok := s.instanceTypes[body.InstanceTypeName]
+	if !ok {
+		return nil, invalidParameter("instance_type_name", "Instance type %q does not exist.", body.InstanceTypeName)
+	}
+	region, ok := s.regions[body.RegionName]
+	if !ok {
+		return nil, invalidParameter("region_name", "Region %q does not exist.", body.RegionName)
+	}
+	if len(body.SshKeyNames) != 1 {
+		return nil, invalidParameter("ssh_key_names", "Exactly one SSH key must be specified.")
+	}
+	if _, ok := s.sshKeyByName(body.SshKeyNames[0]); !ok {
+		return nil, invalidParameter("ssh_key_names", "SSH key %q does not exist.", body.SshKeyNames[0])
+	}
+	fileSystemNames := make([]string, 0)
+	if body.FileSystemNames != nil {
+		if len(*body.FileSystemNames) > 1 {
+			return nil, invalidParameter("file_system_names", "Only one file system may be specified.")
+		}
+		for _, name := range *body.FileSystemNames {
+			fileSystem, ok := s.fileSystemByName(name)
+			if !ok {
+				return nil, invalidParameter("file_system_names", "File system %q does not exist.", name)
+			}
+			if fileSystem.Region.Name != region.Name {
+				return nil, newAPIError(lambdalabs.ErrorCodeFileSystemInWrongRegion,
+					"File system %q is in region %s, not %s.", name, fileSystem.Region.Name, region.Name)
+			}
+			fileSystemNames = append(fileSystemNames, name)
+		}
+	}
+	if body.Name != nil && (len(*body.Name) == 0 || len(*body.Name) > 64) {
+		return nil, invalidParameter("name", "Name must be between 1 and 64 characters.")
+	}
+	quantity := 1
+	if body.Quantity != nil {
+		quantity = *body.Quantity
+	}
+	if quantity < 1 {
+		return nil, invalidParameter("quantity", "Quantity must be at least 1.")
+	}
+
+	capacity := s.capacity[instanceType.Name][region.Name]
+	if capacity <= 0 {
+		return nil, newAPIError(lambdalabs.ErrorCodeInsufficientCapacity,
+			"Not enough capacity to fulfill launch request.")
+	}
+	if quantity > capacity {
+		quantity = capacity
+	}
+	s.capacity[instanceType.Name][region.Name] = capacity - quantity
+
+	ids := make([]string, 0, quantity)
+	for n := 0; n < quantity; n++ {
+		id := newID()
+		ip := fmt.Sprintf("10.0.%d.%d", s.sequence/250, s.sequence%250+2)
+		hostname := strings.ReplaceAll(ip, ".", "-") + ".cloud.lambdalabs.com"
+		jupyterToken := newID()
+		jupyterURL := fmt.Sprintf("https://jupyter-%s.lambdaspaces.com/?token=%s", id, jupyterToken)
+		instanceType := instanceType
+		region := region
+		s.sequence++
+		s.instances[id] = &instance{
+			Instance: lambdalabs.Instance{
+				Id:              id,
+				Name:            body.Name,
+				Ip:              &ip,
+				Hostname:        &hostname,
+				JupyterToken:    &jupyterToken,
+				JupyterUrl:      &jupyterURL,
+				Status:          lambdalabs.InstanceStatusBooting,
+				SshKeyNames:     append([]string{}, body.SshKeyNames...),
+				FileSystemNames: append([]string{}, fileSystemNames...),
+				InstanceType:    &instanceType,
+				Region:          &region,
+			},
+			sequence: s.sequence,
+			pending:  s.bootStatuses(),
+		}
+		ids = append(ids, id)
+	}
+	return map[string]interface{}{"instance_ids": ids}, nil
+}
+
+func (s *Server) bootStatuses() []lambdalabs.InstanceStatus {
+	statuses := make([]lambdalabs.InstanceStatus, 0, s.BootPolls+1)
+	for n := 0; n < s.BootPolls; n++ {
+		statuses = append(statuses, lambdalabs.InstanceStatusBooting)
+	}
+	return append(statuses, lambdalabs.InstanceStatusActive)
+}
+
+func (s *Server) terminateInstance(r *http.Request) (interface{}, *apiError) {
+	var body lambdalabs.TerminateInstanceJSONRequestBody
+	if err := decodeBody(r, &body); err != nil {
+		return nil, err
+	}
+	if err := s.checkInstanceIDs(body.InstanceIds); err != nil {
+		return nil, err
+	}
+
+	terminated := make([]lambdalabs.Instance, 0, len(body.InstanceIds))
+	for _, id := range body.InstanceIds {
+		i := s.instances[id]
+		if i.Status != lambdalabs.InstanceStatusTerminating && i.Status != lambdalabs.InstanceStatusTerminated {
+			i.Status = lambdalabs.InstanceStatusTerminating
+			i.pending = make([]lambdalabs.InstanceStatus, 0, s.TerminatePolls+2)
+			for n := 1; n < s.TerminatePolls; n++ {
+				i.pending = append(i.pending, lambdalabs.InstanceStatusTerminating)
+			}
+			i.pending = append(i.pending, lambdalabs.InstanceStatusTerminated, removed)
+		}
+		terminated = append(terminated, i.Instance)
+	}
+	return map[string]interface{}{"terminated_instances": terminated}, nil
+}
+
+func (s *Server) restartInstance(r *http.Request) (interface{}, *apiError) {
+	var body lambdalabs.RestartInstanceJSONRequestBody
+	if err := decodeBody(r, &body); err != nil {
+		return nil, err
+	}
+	if err := s.checkInstanceIDs(body.InstanceIds); err != nil {
+		return nil, err
+	}
+
+	restarted := make([]lambdalabs.Instance, 0, len(body.InstanceIds))
+	for _, id := range body.InstanceIds {
+		i := s.instances[id]
+		if i.Status == lambdalabs.InstanceStatusTerminating || i.Status == lambdalabs.InstanceStatusTerminated {
+			return nil, invalidParameter("instance_ids", "Instance %s is %s and cannot be restarted.", id, i.Status)
+		}
+		// Without BootPolls, the instance is active again before the response
+		if s.BootPolls > 0 {
+			i.Status = lambdalabs.InstanceStatusBooting
+		}
+		i.pending = s.bootStatuses()
+		restarted = append(restarted, i.Instance)
+	}
+	return map[string]interface{}{"restarted_instances": restarted}, nil
+}
+
+func (s *Server) checkInstanceIDs(ids []string) *apiError {
+	if len(ids) == 0 {
+		return invalidParameter("instance_ids", "At least one instance ID must be specified.")
+	}
+	for _, id := range ids {
+		if _, ok := s.instances[id]; !ok {
+			return newAPIError(lambdalabs.ErrorCodeObjectDoesNotExist, "Instance %s does not exist.", id)
+		}
+	}
+	return nil
+}
+
+func (s *Server) addSSHKey(r *http.Request) (interface{}, *apiError) {
+	var body lambdalabs.AddSSHKeyJSONRequestBody
+	if err := decodeBody(r, &body); err != nil {
+		return nil, err
+	}
+	if body.Name == "" || len(body.Name) > 64 {
+		return nil, invalidParameter("name", "Name must be between 1 and 64 characters.")
+	}
+	if _, ok := s.sshKeyByName(body.Name); ok {
+		return nil, invalidParameter("name", "An SSH key named %q already exists.", body.Name)
+	}
+
+	sshKey := lambdalabs.SshKey{Id: newID(), Name: body.Name}
+	if body.PublicKey != nil {
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(*body.PublicKey)); err != nil {
+			return nil, invalidParameter("public_key", "Invalid public key: %s.", err)
+		}
+		sshKey.PublicKey = strings.TrimSpace(*body.PublicKey)
+	} else {
+		publicKey, privateKey, err := generateKeyPair()
+		if err != nil {
+			return nil, newAPIError(lambdalabs.ErrorCodeUnknown, "Unable to generate a key pair: %s.", err)
+		}
+		sshKey.PublicKey = publicKey
+		sshKey.PrivateKey = &privateKey
+	}
+
+	stored := sshKey
+	stored.PrivateKey = nil
+	s.sshKeys[sshKey.Id] = stored
+	return sshKey, nil
+}
+
+func (s *Server) deleteSSHKey(id string) (interface{}, *apiError) {
+	sshKey, ok := s.sshKeys[id]
+	if !ok {
+		return nil, &apiError{
+			statusCode: http.StatusBadRequest,
+			code:       lambdalabs.ErrorCodeObjectDoesNotExist,
+			message:    fmt.Sprintf("SSH key %s does not exist.", id),
+		}
+	}
+	for _, i := range s.instances {
+		if i.Status == lambdalabs.InstanceStatusTerminated {
+			continue
+		}
+		for _, name := range i.SshKeyNames {
+			if name == sshKey.Name {
+				return nil, newAPIError(lambdalabs.ErrorCodeSSHKeyInUse,
+					"SSH key %q is in use by instance %s.", sshKey.Name, i.Id)
+			}
+		}
+	}
+	delete(s.sshKeys, id)
+	return map[string]interface{}{}, nil
+}
+
+func (s *Server) listFileSystems() []lambdalabs.FileSystem {
+	fileSystems := make([]lambdalabs.FileSystem, 0, len(s.fileSystems))
+	for _, fileSystem := range s.fileSystems {
+		fileSystem.IsInUse = false
+		for _, i := range s.instances {
+			for _, name := range i.FileSystemNames {
+				if name == fileSystem.Name && i.Status != lambdalabs.InstanceStatusTerminated {
+					fileSystem.IsInUse = true
+				}
+			}
+		}
+		fileSystems = append(fileSystems, fileSystem)
+	}
+	sort.Slice(fileSystems, func(i, j int) bool { return fileSystems[i].Name < fileSystems[j].Name })
+	return fileSystems
+}
+
+func (s *Server) sortedInstances() []*instance {
+	instances := make([]*instance, 0, len(s.instances))
+	for _, i := range s.instances {
+		instances = append(instances, i)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].sequence < instances[j].sequence })
+	return instances
+}
+
+func (s *Server) sortedSSHKeys() []lambdalabs.SshKey {
+	sshKeys := make([]lambdalabs.SshKey, 0, len(s.sshKeys))
+	for _, sshKey := range s.sshKeys {
+		sshKeys = append(sshKeys, sshKey)
+	}
+	sort.Slice(sshKeys, func(i, j int) bool { return sshKeys[i].Name < sshKeys[j].Name })
+	return sshKeys
+}
+
+func (s *Server) sshKeyByName(name string) (lambdalabs.SshKey, bool) {
+	for _, sshKey := range s.sshKeys {
+		if sshKey.Name == name {
+			return sshKey, true
+		}
+	}
+	return lambdalabs.SshKey{}, false
+}
+
+func (s *Server) fileSystemByName(name string) (lambdalabs.FileSystem, bool) {
+	for _, fileSystem := range s.fileSystems {
+		if fileSystem.Name == name {
+			return fileSystem, true
+		}
+	}
+	return lambdalabs.FileSystem{}, false
+}
+
+// decodeBody decodes a JSON request body, rejecting unknown properties like the API does.
+func decodeBody(r *http.Request, body interface{}) *apiError {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(body); err != nil {
+		return &apiError{
+			statusCode: http.StatusBadRequest,
+			code:       lambdalabs.ErrorCodeInvalidParameters,
+			message:    fmt.Sprintf("Invalid request body: %s.", err),
+		}
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	body := lambdalabs.ErrorResponseBody{
+		Error: lambdalabs.Error{Code: err.code, Message: err.message},
+	}
+	if len(err.fieldErrors) > 0 {
+		body.FieldErrors = &err.fieldErrors
+	}
+	writeJSON(w, err.statusCode, body)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// generateKeyPair generates an RSA key pair like the API does, and returns the public key in
+// authorized_keys format and the PEM encoded private key.
+func generateKeyPair() (string, string, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))), string(privateKeyPEM), nil
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// launchInstanceFieldPaths maps launch request parameters to the attributes they come from.
+var launchInstanceFieldPaths = map[string]path.Path{
+	"file_system_names":  path.Root("filesystem_names"),
+	"instance_type_name": path.Root("instance_type"),
+	"name":               path.Root("name"),
+	"quantity":           path.Root("quantity"),
+	"region_name":        path.Root("region"),
+	"ssh_key_names":      path.Root("ssh_key_names"),
+}
+
+// addSSHKeyFieldPaths maps add SSH key request parameters to the attributes they come from.
+var addSSHKeyFieldPaths = map[string]path.Path{
+	"name":       path.Root("name"),
+	"public_key": path.Root("public_key"),
+}
+
+// apiErrorDiagnostics turns an error returned while calling the API into error diagnostics.
+// API errors show their message and suggestion, and field errors are attached to the
+// attribute they belong to according to fieldPaths, which may be nil.
+func apiErrorDiagnostics(summary string, err error, fieldPaths map[string]path.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var apiErr *lambdalabs.APIError
+	if !errors.As(err, &apiErr) {
+		if isTransportError(err) {
+			diags.AddError(summary, fmt.Sprintf("Unable to reach the Lambda Labs API, got error: %s", err))
+		} else {
+			diags.AddError(summary, fmt.Sprintf("Lambda Labs API request failed: %s", err))
+		}
+		return diags
+	}
+
+	detail := apiErrorDetail(apiErr.Message, apiErr.Suggestion)
+	if apiErr.Code != "" {
+		detail = fmt.Sprintf("%s\n\nError code: %s (HTTP %d)", detail, apiErr.Code, apiErr.StatusCode)
+	} else {
+		detail = fmt.Sprintf("%s\n\nHTTP status code: %d", detail, apiErr.StatusCode)
+	}
+
+	fields := make([]string, 0, len(apiErr.FieldErrors))
+	for field := range apiErr.FieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	unmapped := make([]string, 0)
+	for _, field := range fields {
+		fieldErr := apiErr.FieldErrors[field]
+		attributePath, ok := fieldPaths[field]
+		if !ok {
+			unmapped = append(unmapped, fmt.Sprintf("%s: %s", field, fieldErr.Message))
+			continue
+		}
+		suggestion := ""
+		if fieldErr.Suggestion != nil {
+			suggestion = *fieldErr.Suggestion
+		}
+		diags.AddAttributeError(attributePath, summary, apiErrorDetail(fieldErr.Message, suggestion))
+	}
+	if len(unmapped) > 0 {
+		detail = fmt.Sprintf("%s\n\n%s", detail, strings.Join(unmapped, "\n"))
+	}
+
+	diags.AddError(summary, detail)
+	return diags
+}
+
+// isTransportError reports whether err comes from sending a request or receiving its response,
+// as opposed to e.g. a missing object or a response that cannot be decoded.
+func isTransportError(err error) bool {
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// apiErrorDetail joins an error message with its suggestion, if any.
+func apiErrorDetail(message string, suggestion string) string {
+	if suggestion == "" {
+		return message
+	}
+	return fmt.Sprintf("%s\n\n%s", message, suggestion)
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+func TestAPIErrorDiagnostics(t *testing.T) {
+	err := lambdalabs.NewAPIError(400, []byte(`{
+		"error": {"code": "global/invalid-parameters", "message": "Invalid request.", "suggestion": "Fix the parameters."},
+		"field_errors": {
+			"region_name": {"code": "global/invalid-parameters", "message": "Unknown region.", "suggestion": "Use us-east-1."},
+			"unmapped": {"code": "global/invalid-parameters", "message": "Something else."}
+		}
+	}`))
+
+	diags := apiErrorDiagnostics("Failed to create instance", err, launchInstanceFieldPaths)
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %v", len(diags), diags)
+	}
+
+	attributeDiag, ok := diags[0].(diag.DiagnosticWithPath)
+	if !ok {
+		t.Fatalf("expected an attribute diagnostic, got %v", diags[0])
+	}
+	if !attributeDiag.Path().Equal(path.Root("region")) {
+		t.Errorf("expected path region, got %s", attributeDiag.Path())
+	}
+	if attributeDiag.Detail() != "Unknown region.\n\nUse us-east-1." {
+		t.Errorf("unexpected attribute detail %q", attributeDiag.Detail())
+	}
+
+	detail := diags[1].Detail()
+	for _, expected := range []string{"Invalid request.", "Fix the parameters.", "global/invalid-parameters", "unmapped: Something else."} {
+		if !strings.Contains(detail, expected) {
+			t.Errorf("expected detail to contain %q, got %q", expected, detail)
+		}
+	}
+}
+
+func TestAPIErrorDiagnosticsTransportError(t *testing.T) {
+	transportErrors := map[string]error{
+		"url": &url.Error{Op: "Get", URL: "https://cloud.lambdalabs.com/api/v1/instances", Err: errors.New("connection refused")},
+		"net": fmt.Errorf("listing instances: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}),
+	}
+	for name, err := range transportErrors {
+		diags := apiErrorDiagnostics("Failed to create instance", err, nil)
+		if len(diags) != 1 || !strings.HasPrefix(diags[0].Detail(), "Unable to reach the Lambda Labs API, got error: ") || !strings.Contains(diags[0].Detail(), "connection refused") {
+			t.Errorf("%s: unexpected diagnostics %v", name, diags)
+		}
+	}
+}
+
+func TestAPIErrorDiagnosticsRequestError(t *testing.T) {
+	requestErrors := map[string]error{
+		"not found": fmt.Errorf("instance 0920582c7ff041399e34823a0be62549: %w", lambdalabs.ErrNotFound),
+		"ambiguous": errors.New(`2 instances are named "twin" ([a b]), use an instance ID instead`),
+		"decode":    fmt.Errorf("unable to decode response: %w", errors.New("unexpected end of JSON input")),
+	}
+	for name, err := range requestErrors {
+		diags := apiErrorDiagnostics("Failed to read instance", err, nil)
+		if len(diags) != 1 || diags[0].Detail() != "Lambda Labs API request failed: "+err.Error() {
+			t.Errorf("%s: unexpected diagnostics %v", name, diags)
+		}
+	}
+}
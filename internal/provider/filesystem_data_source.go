@@ -22,7 +22,7 @@ func NewFilesystemDataSource() datasource.DataSource {
 
 // filesystemsDataSource is the data source implementation.
 type filesystemsDataSource struct {
-	client *lambdalabs.ClientWithResponses
+	fileSystems lambdalabs.FileSystemsService
 }
 
 // filesystemsDataSourceModel maps the data source schema data.
@@ -87,48 +87,38 @@ func (d *filesystemsDataSource) Schema(_ context.Context, _ datasource.SchemaReq
 	}
 }
 
-// Configure adds the provider configured client to the data source.
+// Configure adds the provider configured services to the data source.
 func (d *filesystemsDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
 
 	if req.ProviderData == nil {
 		return
 	}
 
-	client, ok := req.ProviderData.(*lambdalabs.ClientWithResponses)
+	services, ok := req.ProviderData.(*lambdalabs.Services)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *lambdalabs.Client, got %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *lambdalabs.Services, got %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.fileSystems = services.FileSystems
 }
 
 // Read refreshes the Terraform state with the latest data.
 func (d *filesystemsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
 	var state filesystemsDataSourceModel
 
-	response, err := d.client.ListFileSystemsWithResponse(ctx)
+	filesystems, err := d.fileSystems.List(ctx)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to read file systems. Lambda Labs Client Error",
-			err.Error(),
-		)
-		return
-	}
-	if response.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs Filesystems",
-			string(response.Body),
-		)
+		resp.Diagnostics.Append(apiErrorDiagnostics("Unable to Read Lambda Labs Filesystems", err, nil)...)
 		return
 	}
 
 	// Map response body to model
-	for _, filesystem := range response.JSON200.Data {
+	for _, filesystem := range filesystems {
 		filesystemState := filesystemModel{
 			ID:        types.StringValue(filesystem.Id),
 			Name:      types.StringValue(filesystem.Name),
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"regexp"
+	"terraform-provider-lambdalabs/internal/fakelambda"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccFilesystemsDataSource(t *testing.T) {
+	server := testAccFakeServer(t)
+	fileSystem := server.AddFileSystem("datasets", "us-west-1")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig + `data "lambdalabs_filesystems" "test" {}`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("data.lambdalabs_filesystems.test", tfjsonpath.New("filesystems"), knownvalue.ListExact([]knownvalue.Check{
+						knownvalue.ObjectPartial(map[string]knownvalue.Check{
+							"id":          knownvalue.StringExact(fileSystem.Id),
+							"name":        knownvalue.StringExact("datasets"),
+							"mount_point": knownvalue.StringExact("/home/ubuntu/datasets"),
+							"region": knownvalue.ObjectPartial(map[string]knownvalue.Check{
+								"name": knownvalue.StringExact("us-west-1"),
+							}),
+							"is_in_use": knownvalue.Bool(false),
+						}),
+					})),
+				},
+			},
+			{
+				PreConfig: func() {
+					server.InjectError(fakelambda.OperationListFileSystems, lambdalabs.ErrorCodeAccountInactive, 1)
+				},
+				Config:      testAccProviderConfig + `data "lambdalabs_filesystems" "test" {}`,
+				ExpectError: regexp.MustCompile(`global/account-inactive`),
+			},
+		},
+	})
+}
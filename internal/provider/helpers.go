@@ -1,6 +1,13 @@
 package provider
 
-import "github.com/hashicorp/terraform-plugin-framework/types"
+import (
+	"fmt"
+	"github.com/agext/levenshtein"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"sort"
+	"strings"
+	"time"
+)
 
 // makeTfStringList converts a slice of strings to a slice of types.String.
 func makeTfStringList(rawStrings []string) []types.String {
@@ -28,3 +35,66 @@ func makeOptionalTfInt64(rawInt *int) types.Int64 {
 	}
 	return types.Int64PointerValue(&val)
 }
+
+// containsString reports whether value is present in values.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDuration converts a duration string attribute to a time.Duration,
+// falling back to defaultValue when the attribute is null or unknown.
+func parseDuration(value types.String, defaultValue time.Duration) (time.Duration, error) {
+	if value.IsNull() || value.IsUnknown() {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value.ValueString())
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse duration %q: %w", value.ValueString(), err)
+	}
+	return duration, nil
+}
+
+// didYouMean suggests the options closest to a misspelled value, formatted to be appended to
+// an error message. It returns an empty string when no option is close enough.
+func didYouMean(value string, options []string) string {
+	type suggestion struct {
+		option   string
+		distance int
+	}
+	maxDistance := len(value) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	suggestions := make([]suggestion, 0)
+	for _, option := range options {
+		distance := levenshtein.Distance(value, option, nil)
+		if distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{option: option, distance: distance})
+		}
+	}
+	if len(suggestions) == 0 {
+		return ""
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].option < suggestions[j].option
+	})
+	if len(suggestions) > 3 {
+		suggestions = suggestions[:3]
+	}
+
+	quoted := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		quoted = append(quoted, fmt.Sprintf("%q", s.option))
+	}
+	return fmt.Sprintf(" Did you mean %s?", strings.Join(quoted, " or "))
+}
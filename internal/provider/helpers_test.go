@@ -0,0 +1,18 @@
+package provider
+
+import "testing"
+
+func TestDidYouMean(t *testing.T) {
+	options := []string{"gpu_1x_a10", "gpu_1x_a100", "gpu_8x_a100", "us-east-1", "us-west-1"}
+
+	tests := map[string]string{
+		"gpu_1x_a1000": ` Did you mean "gpu_1x_a100" or "gpu_1x_a10" or "gpu_8x_a100"?`,
+		"us-west-2":    ` Did you mean "us-west-1" or "us-east-1"?`,
+		"eu-central-1": "",
+	}
+	for value, expected := range tests {
+		if actual := didYouMean(value, options); actual != expected {
+			t.Errorf("didYouMean(%q) = %q, expected %q", value, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"reflect"
+	"regexp"
+	"terraform-provider-lambdalabs/internal/fakelambda"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccInstanceDataSource(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccInstanceResourceConfig(`
+  name          = "acceptance-test"
+  instance_type = "gpu_1x_a10"
+  region        = "us-east-1"
+`) + `
+data "lambdalabs_instance" "test" {
+  id = lambdalabs_instance.test.id
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.lambdalabs_instance.test", "id", "lambdalabs_instance.test", "id"),
+					resource.TestCheckResourceAttrPair("data.lambdalabs_instance.test", "ip", "lambdalabs_instance.test", "ip"),
+					resource.TestCheckResourceAttr("data.lambdalabs_instance.test", "name", "acceptance-test"),
+					resource.TestCheckResourceAttr("data.lambdalabs_instance.test", "status", "active"),
+					resource.TestCheckResourceAttr("data.lambdalabs_instance.test", "instance_type.name", "gpu_1x_a10"),
+					resource.TestCheckResourceAttr("data.lambdalabs_instance.test", "instance_type.specs.vcpus", "30"),
+					resource.TestCheckResourceAttr("data.lambdalabs_instance.test", "region.name", "us-east-1"),
+					resource.TestCheckResourceAttr("data.lambdalabs_instance.test", "ssh_key_names.0", "acceptance-test"),
+				),
+			},
+			{
+				Config: testAccProviderConfig + `
+data "lambdalabs_instance" "missing" {
+  id = "0920582c7ff041399e34823a0be62549"
+}
+`,
+				ExpectError: regexp.MustCompile(`not found`),
+			},
+		},
+	})
+}
+
+func TestInstanceDataSourceRead(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	instance := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+
+	state, diags := p.readDataSource("lambdalabs_instance", map[string]interface{}{
+		"id": testString(t, instance, "id"),
+	})
+	requireNoErrors(t, "Read", diags)
+	for _, name := range []string{"id", "ip", "hostname", "jupyter_token", "jupyter_url"} {
+		if expected, actual := testString(t, instance, name), testString(t, state, name); actual != expected {
+			t.Errorf("expected %s %q, got %q", name, expected, actual)
+		}
+	}
+	if name := testString(t, state, "name"); name != "acceptance-test" {
+		t.Errorf("expected name acceptance-test, got %q", name)
+	}
+	if status := testString(t, state, "status"); status != "active" {
+		t.Errorf("expected status active, got %q", status)
+	}
+	if instanceType := testString(t, state, "instance_type", "name"); instanceType != "gpu_1x_a10" {
+		t.Errorf("expected instance type gpu_1x_a10, got %q", instanceType)
+	}
+	if region := testString(t, state, "region", "name"); region != "us-east-1" {
+		t.Errorf("expected region us-east-1, got %q", region)
+	}
+	if sshKeyNames := testStrings(t, state, "ssh_key_names"); !reflect.DeepEqual(sshKeyNames, []string{"acceptance-test"}) {
+		t.Errorf("expected SSH key acceptance-test, got %v", sshKeyNames)
+	}
+
+	_, diags = p.readDataSource("lambdalabs_instance", map[string]interface{}{
+		"id": "0920582c7ff041399e34823a0be62549",
+	})
+	requireError(t, diags, "not found")
+}
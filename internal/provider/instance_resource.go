@@ -5,11 +5,16 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/retry"
+	"strings"
 	"terraform-provider-lambdalabs/pgk/lambdalabs"
 	"time"
 
@@ -17,14 +22,36 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/setplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
+const (
+	// defaultInstanceCreateTimeout covers both the capacity wait and booting the instance.
+	defaultInstanceCreateTimeout = 40 * time.Minute
+	defaultInstanceUpdateTimeout = 20 * time.Minute
+	defaultInstanceDeleteTimeout = 20 * time.Minute
+	defaultCapacityTimeout       = 20 * time.Minute
+	// https://docs.lambdalabs.com/cloud/rate-limiting/
+	defaultPollInterval = 2 * time.Second
+	// defaultCapacityTimeoutValue and defaultPollIntervalValue are the defaults of the
+	// capacity_timeout and poll_interval attributes, as they appear in state.
+	defaultCapacityTimeoutValue = "20m"
+	defaultPollIntervalValue    = "2s"
+
+	// instanceImportNamePrefix marks import IDs that refer to an instance by name.
+	instanceImportNamePrefix = "name:"
+)
+
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &InstanceResource{}
 var _ resource.ResourceWithConfigure = &InstanceResource{}
 var _ resource.ResourceWithImportState = &InstanceResource{}
+var _ resource.ResourceWithValidateConfig = &InstanceResource{}
+var _ resource.ResourceWithModifyPlan = &InstanceResource{}
 
 func NewInstanceResource() resource.Resource {
 	return &InstanceResource{}
@@ -32,7 +59,10 @@ func NewInstanceResource() resource.Resource {
 
 // InstanceResource defines the resource implementation.
 type InstanceResource struct {
-	client *lambdalabs.ClientWithResponses
+	instances     lambdalabs.InstancesService
+	instanceTypes lambdalabs.InstanceTypesService
+	fileSystems   lambdalabs.FileSystemsService
+	sshKeys       lambdalabs.SSHKeysService
 }
 
 func (r *InstanceResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -42,12 +72,43 @@ func (r *InstanceResource) Metadata(ctx context.Context, req resource.MetadataRe
 func (r *InstanceResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		// This description is used by the documentation generator and the language server.
-		MarkdownDescription: "Lambda Labs VM Instance resource.",
+		MarkdownDescription: "Lambda Labs VM Instance resource. The instance type, region, SSH keys and filesystems are checked against the API during plan.",
 
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
 				Computed:            true,
-				MarkdownDescription: "Unique identifier of the instance. valid when `quantity` is 1 (the default).",
+				MarkdownDescription: "Unique identifier of the instance. When `quantity` is greater than 1, this is the ID of the first instance, see `ids` for all of them.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"ids": schema.SetAttribute{
+				Computed:            true,
+				ElementType:         types.StringType,
+				MarkdownDescription: "Unique identifiers of all instances launched by this resource.",
+				PlanModifiers: []planmodifier.Set{
+					setplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"quantity": schema.Int64Attribute{
+				MarkdownDescription: "Number of identical instances to launch. Defaults to 1. The API launches one instance per request, so they are launched one at a time. " +
+					"Increasing it launches the additional instances in place, and so does the next apply after instances are terminated outside of Terraform, " +
+					"whereas decreasing it replaces all instances. " +
+					"Cannot be greater than 1 when `filesystem_names` is set, since a filesystem cannot be attached to multiple instances.",
+				Optional: true,
+				Computed: true,
+				Default:  Int64Default{defaultValue: 1},
+				Validators: []validator.Int64{
+					Int64AtLeast{min: 1},
+				},
+				PlanModifiers: []planmodifier.Int64{
+					// Instances missing from a larger quantity are launched in place
+					int64planmodifier.RequiresReplaceIf(
+						requiresReplaceIfQuantityDecreased,
+						"Requires replacement if quantity decreases.",
+						"Requires replacement if quantity decreases.",
+					),
+				},
 			},
 			"name": schema.StringAttribute{
 				MarkdownDescription: "User-provided name of the instance",
@@ -81,21 +142,134 @@ func (r *InstanceResource) Schema(ctx context.Context, req resource.SchemaReques
 				},
 			},
 			"instance_type": schema.StringAttribute{
-				MarkdownDescription: "Name of an instance type",
-				Required:            true,
+				MarkdownDescription: "Name of an instance type. Required unless `candidates` is set, " +
+					"in which case it holds the instance type of the candidate that was launched.",
+				Optional: true,
+				Computed: true,
 				PlanModifiers: []planmodifier.String{
+					// Keep the launched candidate when the instance type comes from candidates
+					stringplanmodifier.UseStateForUnknown(),
 					// Must redeploy if instance type changes
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
 			"region": schema.StringAttribute{
-				MarkdownDescription: "Name of the region where the instance is located",
-				Required:            true,
+				MarkdownDescription: "Name of the region where the instance is located. Required unless `candidates` is set, " +
+					"in which case it holds the region of the candidate that was launched.",
+				Optional: true,
+				Computed: true,
 				PlanModifiers: []planmodifier.String{
+					// Keep the launched candidate when the region comes from candidates
+					stringplanmodifier.UseStateForUnknown(),
 					// Must redeploy if region changes
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
+			"candidates": schema.ListNestedAttribute{
+				MarkdownDescription: "Ordered list of instance type and region pairs to fall back on. " +
+					"The first candidate with available capacity is launched, and recorded in `instance_type` and `region`. " +
+					"Conflicts with `instance_type` and `region`.",
+				Optional: true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"instance_type": schema.StringAttribute{
+							MarkdownDescription: "Name of an instance type",
+							Required:            true,
+						},
+						"region": schema.StringAttribute{
+							MarkdownDescription: "Name of a region",
+							Required:            true,
+						},
+					},
+				},
+				Validators: []validator.List{
+					ListMinLength{min: 1},
+				},
+				PlanModifiers: []planmodifier.List{
+					// Only redeploy if the launched candidate is no longer acceptable
+					listplanmodifier.RequiresReplaceIf(
+						requiresReplaceIfLaunchedCandidateRemoved,
+						"Requires replacement if the launched instance type and region are no longer candidates.",
+						"Requires replacement if the launched instance type and region are no longer candidates.",
+					),
+				},
+			},
+			"hostname": schema.StringAttribute{
+				MarkdownDescription: "Hostname assigned to the instance, which resolves to the instance's IP.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"ip": schema.StringAttribute{
+				MarkdownDescription: "IPv4 address of the instance",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"jupyter_token": schema.StringAttribute{
+				MarkdownDescription: "Secret token used to log into the jupyter lab server hosted on the instance.",
+				Computed:            true,
+				Sensitive:           true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"jupyter_url": schema.StringAttribute{
+				MarkdownDescription: "URL that opens a jupyter lab notebook on the instance.",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"status": schema.StringAttribute{
+				MarkdownDescription: "The current status of the instance",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"capacity_timeout": schema.StringAttribute{
+				MarkdownDescription: "How long to wait for the instance type to have capacity in the region before giving up, " +
+					"as a duration string such as `30s`, `20m` or `6h`. The wait is also bounded by the `create` timeout. Defaults to `20m`.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString(defaultCapacityTimeoutValue),
+				Validators: []validator.String{
+					DurationValidator{},
+				},
+			},
+			"poll_interval": schema.StringAttribute{
+				MarkdownDescription: "How often to poll the API while waiting for capacity or for the instance status to change, " +
+					"as a duration string. Defaults to `2s`.",
+				Optional: true,
+				Computed: true,
+				Default:  stringdefault.StaticString(defaultPollIntervalValue),
+				Validators: []validator.String{
+					DurationValidator{},
+				},
+			},
+			"restart_triggers": schema.MapAttribute{
+				MarkdownDescription: "Arbitrary map of values that, when changed, restart the instances in place " +
+					"and wait for them to become active again, instead of replacing them. " +
+					"Local disks are preserved. Adding the attribute, e.g. after an import, or removing it does not restart the instances.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Create: true,
+				CreateDescription: "How long to wait for capacity and for the instance to become active, " +
+					"as a duration string such as `40m` or `6h`. Defaults to `40m`.",
+				Update: true,
+				UpdateDescription: "How long to wait for the instances to be seen booting and active again after a restart " +
+					"triggered by `restart_triggers`, as a duration string. Defaults to `20m`.",
+				Delete: true,
+				DeleteDescription: "How long to wait for the instance to terminate, as a duration string. " +
+					"Defaults to `20m`.",
+			}),
 		},
 	}
 }
@@ -106,117 +280,602 @@ func (r *InstanceResource) Configure(ctx context.Context, req resource.Configure
 		return
 	}
 
-	client, ok := req.ProviderData.(*lambdalabs.ClientWithResponses)
+	services, ok := req.ProviderData.(*lambdalabs.Services)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *lambdalabs.ClientWithResponses, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *lambdalabs.Services, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
 
-	r.client = client
+	r.instances = services.Instances
+	r.instanceTypes = services.InstanceTypes
+	r.fileSystems = services.FileSystems
+	r.sshKeys = services.SSHKeys
 }
 
-func (r *InstanceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	var data InstanceResourceModel
+func (r *InstanceResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var quantity types.Int64
+	var fileSystemNames types.List
+	var instanceType types.String
+	var region types.String
+	var candidates types.List
+
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("quantity"), &quantity)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("filesystem_names"), &fileSystemNames)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("instance_type"), &instanceType)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("region"), &region)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("candidates"), &candidates)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	// Read Terraform plan data into the model
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if candidates.IsNull() {
+		if instanceType.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("instance_type"),
+				"Missing instance_type",
+				"Either instance_type and region, or candidates must be set.",
+			)
+		}
+		if region.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("region"),
+				"Missing region",
+				"Either instance_type and region, or candidates must be set.",
+			)
+		}
+	} else {
+		if !instanceType.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("instance_type"),
+				"Conflicting instance_type and candidates",
+				"instance_type cannot be set together with candidates, add it as a candidate instead.",
+			)
+		}
+		if !region.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("region"),
+				"Conflicting region and candidates",
+				"region cannot be set together with candidates, add it as a candidate instead.",
+			)
+		}
+	}
 
+	if quantity.IsNull() || quantity.IsUnknown() || quantity.ValueInt64() <= 1 {
+		return
+	}
+	if fileSystemNames.IsNull() || fileSystemNames.IsUnknown() || len(fileSystemNames.Elements()) == 0 {
+		return
+	}
+	resp.Diagnostics.AddAttributeError(
+		path.Root("quantity"),
+		"Invalid quantity with filesystem_names",
+		fmt.Sprintf("A filesystem can only be attached to a single instance, but quantity is %d. "+
+			"Remove filesystem_names or set quantity to 1.", quantity.ValueInt64()),
+	)
+}
+
+// ModifyPlan checks the instance type, region, SSH keys and filesystems of instances that are
+// about to be launched against the API, so that mistakes surface during plan instead of apply.
+func (r *InstanceResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing to check when destroying, or before the provider is configured
+	if req.Plan.Raw.IsNull() || r.instanceTypes == nil {
+		return
+	}
+	// Update launches the missing instances when there are fewer than quantity, because quantity
+	// increased or instances were terminated out of band
+	if !req.State.Raw.IsNull() && len(resp.RequiresReplace) == 0 {
+		var plannedQuantity types.Int64
+		var ids types.Set
+		resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("quantity"), &plannedQuantity)...)
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("ids"), &ids)...)
+		if !resp.Diagnostics.HasError() && (plannedQuantity.IsUnknown() || int64(len(ids.Elements())) < plannedQuantity.ValueInt64()) {
+			resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("ids"), types.SetUnknown(types.StringType))...)
+		}
+	}
+	// Only check instances that are about to be launched
+	if !req.State.Raw.IsNull() && len(resp.RequiresReplace) == 0 {
+		return
+	}
+
+	candidates, candidatePaths, diags := instanceCandidatesFromConfig(ctx, req.Config)
+	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	err := retry.RetryContext(ctx, 20*time.Minute, func() *retry.RetryError {
-		instanceTypesResponse, err := r.client.InstanceTypesWithResponse(ctx)
-		if err != nil {
-			return retry.NonRetryableError(fmt.Errorf("unable to read available instance types, got error: %s", err))
+	var sshKeyNames types.List
+	var fileSystemNames types.List
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("ssh_key_names"), &sshKeyNames)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("filesystem_names"), &fileSystemNames)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	r.validateInstanceTypes(ctx, candidates, candidatePaths, &resp.Diagnostics)
+	r.validateSSHKeys(ctx, sshKeyNames, &resp.Diagnostics)
+	r.validateFileSystems(ctx, fileSystemNames, candidates, candidatePaths, &resp.Diagnostics)
+}
+
+// instanceCandidatesFromConfig returns the configured candidates, or the single instance_type
+// and region pair, along with the paths to report problems with each candidate at.
+func instanceCandidatesFromConfig(ctx context.Context, config tfsdk.Config) ([]instanceCandidateModel, []path.Path, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	var candidatesList types.List
+	diags.Append(config.GetAttribute(ctx, path.Root("candidates"), &candidatesList)...)
+	if diags.HasError() || candidatesList.IsUnknown() {
+		return nil, nil, diags
+	}
+
+	if candidatesList.IsNull() {
+		var candidate instanceCandidateModel
+		diags.Append(config.GetAttribute(ctx, path.Root("instance_type"), &candidate.InstanceType)...)
+		diags.Append(config.GetAttribute(ctx, path.Root("region"), &candidate.Region)...)
+		return []instanceCandidateModel{candidate}, []path.Path{path.Empty()}, diags
+	}
+
+	var candidates []instanceCandidateModel
+	diags.Append(candidatesList.ElementsAs(ctx, &candidates, true)...)
+	paths := make([]path.Path, 0, len(candidates))
+	for i := range candidates {
+		paths = append(paths, path.Root("candidates").AtListIndex(i))
+	}
+	return candidates, paths, diags
+}
+
+// candidateAttributePath returns the path of an attribute of a candidate, which is either
+// nested in candidates or at the root of the resource.
+func candidateAttributePath(candidatePath path.Path, name string) path.Path {
+	if candidatePath.Equal(path.Empty()) {
+		return path.Root(name)
+	}
+	return candidatePath.AtName(name)
+}
+
+// validateInstanceTypes reports unknown instance types as errors, and unknown regions and
+// missing capacity as warnings. Regions cannot be listed through the API, so a region only
+// counts as known if some instance type currently has capacity in it.
+func (r *InstanceResource) validateInstanceTypes(ctx context.Context, candidates []instanceCandidateModel, candidatePaths []path.Path, diags *diag.Diagnostics) {
+	instanceTypes, err := r.instanceTypes.List(ctx)
+	if err != nil {
+		diags.AddWarning("Unable to validate instance_type", fmt.Sprintf("Unable to read available instance types, got error: %s", err))
+		return
+	}
+
+	instanceTypeNames := make([]string, 0, len(instanceTypes))
+	regionNames := make([]string, 0)
+	for name, instanceType := range instanceTypes {
+		instanceTypeNames = append(instanceTypeNames, name)
+		for _, region := range instanceType.RegionsWithCapacityAvailable {
+			if !containsString(regionNames, region.Name) {
+				regionNames = append(regionNames, region.Name)
+			}
 		}
-		if instanceTypesResponse.JSON200 == nil {
-			return retry.NonRetryableError(fmt.Errorf("unable to read available instance types, got error: %s", instanceTypesResponse.Body))
+	}
+
+	anyUnknown := false
+	hasCapacity := false
+	for i, candidate := range candidates {
+		if candidate.InstanceType.IsUnknown() || candidate.InstanceType.IsNull() || candidate.Region.IsUnknown() || candidate.Region.IsNull() {
+			anyUnknown = true
+			continue
 		}
-		instanceTypes := instanceTypesResponse.JSON200.Data
-		instanceAvailability, ok := instanceTypes[data.InstanceTypeName.ValueString()]
+		instanceType := candidate.InstanceType.ValueString()
+		region := candidate.Region.ValueString()
+
+		availability, ok := instanceTypes[instanceType]
 		if !ok {
-			return retry.NonRetryableError(fmt.Errorf("instance type %s not found in available instance types", data.InstanceTypeName.ValueString()))
+			diags.AddAttributeError(
+				candidateAttributePath(candidatePaths[i], "instance_type"),
+				"Unknown instance type",
+				fmt.Sprintf("Instance type %q does not exist.%s", instanceType, didYouMean(instanceType, instanceTypeNames)),
+			)
+			continue
+		}
+		if !containsString(regionNames, region) {
+			diags.AddAttributeWarning(
+				candidateAttributePath(candidatePaths[i], "region"),
+				"Unknown region",
+				fmt.Sprintf("No instance type currently has capacity in region %q, it may not exist.%s", region, didYouMean(region, regionNames)),
+			)
+			continue
 		}
+		for _, availableRegion := range availability.RegionsWithCapacityAvailable {
+			if availableRegion.Name == region {
+				hasCapacity = true
+			}
+		}
+	}
 
-		regions := make(map[string]lambdalabs.Region)
-		for _, region := range instanceAvailability.RegionsWithCapacityAvailable {
-			regions[region.Name] = region
+	if !hasCapacity && !anyUnknown && !diags.HasError() {
+		diags.AddWarning(
+			"No capacity available",
+			fmt.Sprintf("There is currently no capacity for %s. Apply will wait for capacity for up to capacity_timeout.",
+				strings.Join(describeCandidates(candidates), ", ")),
+		)
+	}
+}
+
+// validateSSHKeys warns about SSH keys that do not exist. These are not errors, since the
+// keys may be created by a lambdalabs_ssh_key resource in the same apply.
+func (r *InstanceResource) validateSSHKeys(ctx context.Context, sshKeyNames types.List, diags *diag.Diagnostics) {
+	if sshKeyNames.IsNull() || sshKeyNames.IsUnknown() {
+		return
+	}
+
+	sshKeys, err := r.sshKeys.List(ctx)
+	if err != nil {
+		diags.AddWarning("Unable to validate ssh_key_names", fmt.Sprintf("Unable to read SSH keys, got error: %s", err))
+		return
+	}
+
+	existing := make([]string, 0, len(sshKeys))
+	for _, sshKey := range sshKeys {
+		existing = append(existing, sshKey.Name)
+	}
+
+	for i, element := range sshKeyNames.Elements() {
+		name, ok := element.(types.String)
+		if !ok || name.IsNull() || name.IsUnknown() || containsString(existing, name.ValueString()) {
+			continue
 		}
-		_, ok = regions[data.RegionName.ValueString()]
+		diags.AddAttributeWarning(
+			path.Root("ssh_key_names").AtListIndex(i),
+			"Unknown SSH key",
+			fmt.Sprintf("SSH key %q does not exist yet. Unless it is created in this apply, launching the instance will fail.%s",
+				name.ValueString(), didYouMean(name.ValueString(), existing)),
+		)
+	}
+}
+
+// validateFileSystems reports filesystems that do not exist, or that are in a different region
+// than the instance (the file-system-in-wrong-region API error), as errors.
+func (r *InstanceResource) validateFileSystems(ctx context.Context, fileSystemNames types.List, candidates []instanceCandidateModel, candidatePaths []path.Path, diags *diag.Diagnostics) {
+	if fileSystemNames.IsNull() || fileSystemNames.IsUnknown() || len(fileSystemNames.Elements()) == 0 {
+		return
+	}
+
+	fileSystems, err := r.fileSystems.List(ctx)
+	if err != nil {
+		diags.AddWarning("Unable to validate filesystem_names", fmt.Sprintf("Unable to read filesystems, got error: %s", err))
+		return
+	}
+
+	regions := make(map[string]string)
+	existing := make([]string, 0, len(fileSystems))
+	for _, fileSystem := range fileSystems {
+		regions[fileSystem.Name] = fileSystem.Region.Name
+		existing = append(existing, fileSystem.Name)
+	}
+
+	for i, element := range fileSystemNames.Elements() {
+		name, ok := element.(types.String)
+		if !ok || name.IsNull() || name.IsUnknown() {
+			continue
+		}
+		fileSystemRegion, ok := regions[name.ValueString()]
 		if !ok {
-			// https://docs.lambdalabs.com/cloud/rate-limiting/
-			// Default retry delay is 500ms, so we sleep for 2 more seconds to play nice with the API
-			time.Sleep(2 * time.Second)
-			return retry.RetryableError(fmt.Errorf("no capacity available in region %s", data.RegionName.ValueString()))
+			diags.AddAttributeError(
+				path.Root("filesystem_names").AtListIndex(i),
+				"Unknown filesystem",
+				fmt.Sprintf("Filesystem %q does not exist.%s", name.ValueString(), didYouMean(name.ValueString(), existing)),
+			)
+			continue
+		}
+		for j, candidate := range candidates {
+			if candidate.Region.IsNull() || candidate.Region.IsUnknown() || candidate.Region.ValueString() == fileSystemRegion {
+				continue
+			}
+			diags.AddAttributeError(
+				candidateAttributePath(candidatePaths[j], "region"),
+				"Filesystem in wrong region",
+				fmt.Sprintf("Filesystem %q is in region %q, but the instance would be launched in region %q. "+
+					"Filesystems can only be attached to instances in the same region.",
+					name.ValueString(), fileSystemRegion, candidate.Region.ValueString()),
+			)
 		}
-		return nil
-	})
+	}
+}
+
+func (r *InstanceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data InstanceResourceModel
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	createTimeout, diags := data.Timeouts.Create(ctx, defaultInstanceCreateTimeout)
+	resp.Diagnostics.Append(diags...)
+	capacityTimeout, err := parseDuration(data.CapacityTimeout, defaultCapacityTimeout)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("capacity_timeout"), "Invalid capacity_timeout", err.Error())
+	}
+	pollInterval, err := parseDuration(data.PollInterval, defaultPollInterval)
 	if err != nil {
-		resp.Diagnostics.AddError("Instance type unavailable", fmt.Sprintf("Unable to create instance, got error: %s", err))
+		resp.Diagnostics.AddAttributeError(path.Root("poll_interval"), "Invalid poll_interval", err.Error())
+	}
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, createTimeout)
+	defer cancel()
+
+	candidates := data.Candidates
+	if len(candidates) == 0 {
+		candidates = []instanceCandidateModel{{InstanceType: data.InstanceTypeName, Region: data.RegionName}}
+	}
+
+	// ValidateConfig ensures that quantity is 1 whenever a filesystem is attached
+	var quantity = int(data.Quantity.ValueInt64())
+
+	instanceIDs, launchDiags := r.launchInstances(ctx, &data, candidates, quantity, capacityTimeout, pollInterval)
+	resp.Diagnostics.Append(launchDiags...)
+	if len(instanceIDs) == 0 {
+		return
+	}
+
+	if len(instanceIDs) == 1 {
+		tflog.Trace(ctx, "created new instance", map[string]interface{}{"id": instanceIDs[0]})
+	} else {
+		tflog.Trace(ctx, "created new instances", map[string]interface{}{"ids": instanceIDs})
+	}
+
+	data.ID = types.StringValue(instanceIDs[0])
+	ids, diags := types.SetValueFrom(ctx, types.StringType, instanceIDs)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+	data.IDs = ids
+
+	// Until the instances are active, persist only their IDs so that Terraform taints
+	// them on failure instead of losing track of them.
+	data.Hostname = types.StringNull()
+	data.Ip = types.StringNull()
+	data.JupyterToken = types.StringNull()
+	data.JupyterUrl = types.StringNull()
+	data.Status = types.StringNull()
+
+	if launchDiags.HasError() {
+		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+		resp.Diagnostics.AddError(
+			"Failed to create all instances",
+			fmt.Sprintf("Only %d of %d instances were launched: %s", len(instanceIDs), quantity, instanceIDs),
+		)
+		return
+	}
+
+	var instance *lambdalabs.Instance
+	for _, id := range instanceIDs {
+		launched, err := r.waitForInstanceActive(ctx, id, pollInterval)
+		if err != nil {
+			resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			resp.Diagnostics.AddError(
+				"Instance failed to become active",
+				fmt.Sprintf("Instance %s was launched but did not become active, got error: %s", id, err),
+			)
+			return
+		}
+		if instance == nil {
+			instance = launched
+		}
+	}
+
+	setInstanceComputedAttributes(&data, *instance)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// launchInstances launches count instances with the instance type, region and settings of data.
+// The API launches a single instance per request, so they are launched one at a time, each once
+// there is capacity for it. The first candidate with capacity is recorded in data, and the
+// other instances are launched with the same instance type and region. The IDs of the instances
+// launched so far are returned even when launching the rest fails.
+func (r *InstanceResource) launchInstances(ctx context.Context, data *InstanceResourceModel, candidates []instanceCandidateModel, count int, capacityTimeout time.Duration, pollInterval time.Duration) ([]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
 	var fileSystemNames = make([]string, 0)
 	if data.FileSystemNames != nil {
 		fileSystemNames = makeStringListFromTf(data.FileSystemNames)
 	}
-
-	// TODO: Add support multiple instances
-	// Considerations:
-	//  - Mutually exclusive with file systems, cannot attach same fs to multiple instances
 	var quantity = 1
 
-	body := lambdalabs.LaunchInstanceJSONRequestBody{
-		FileSystemNames:  &fileSystemNames,
-		InstanceTypeName: data.InstanceTypeName.ValueString(),
-		Name:             data.Name.ValueStringPointer(),
-		Quantity:         &quantity,
-		RegionName:       data.RegionName.ValueString(),
-		SshKeyNames:      makeStringListFromTf(data.SshKeyNames),
+	// Capacity may be taken by someone else between seeing it and launching, in which case
+	// the launch fails with insufficient-capacity and we go back to waiting for capacity.
+	capacityDeadline := time.Now().Add(capacityTimeout)
+	ids := make([]string, 0, count)
+	for len(ids) < count {
+		candidate, err := r.waitForCapacity(ctx, candidates, time.Until(capacityDeadline), pollInterval)
+		if err != nil {
+			diags.AddError("Instance type unavailable", fmt.Sprintf("Unable to create instance, got error: %s", err))
+			return ids, diags
+		}
+		data.InstanceTypeName = candidate.InstanceType
+		data.RegionName = candidate.Region
+		candidates = []instanceCandidateModel{candidate}
+
+		body := lambdalabs.LaunchInstanceJSONRequestBody{
+			FileSystemNames:  &fileSystemNames,
+			InstanceTypeName: data.InstanceTypeName.ValueString(),
+			Name:             data.Name.ValueStringPointer(),
+			Quantity:         &quantity,
+			RegionName:       data.RegionName.ValueString(),
+			SshKeyNames:      makeStringListFromTf(data.SshKeyNames),
+		}
+
+		launched, err := r.instances.Launch(ctx, body)
+		if lambdalabs.IsErrorCode(err, lambdalabs.ErrorCodeInsufficientCapacity) && time.Now().Add(pollInterval).Before(capacityDeadline) {
+			tflog.Debug(ctx, "capacity was taken before the instance launched, waiting for capacity again", map[string]interface{}{
+				"instance_type": data.InstanceTypeName.ValueString(),
+				"region":        data.RegionName.ValueString(),
+			})
+			select {
+			case <-ctx.Done():
+				diags.Append(apiErrorDiagnostics("Failed to create instance", err, launchInstanceFieldPaths)...)
+				return ids, diags
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		if err != nil {
+			diags.Append(apiErrorDiagnostics("Failed to create instance", err, launchInstanceFieldPaths)...)
+			return ids, diags
+		}
+		if len(launched) == 0 {
+			diags.AddError(
+				"Failed to create instance",
+				"Unable to create instance, the API accepted the request but no instances were launched",
+			)
+			return ids, diags
+		}
+		ids = append(ids, launched...)
+	}
+	return ids, diags
+}
+
+// waitForCapacity polls the available instance types until one of the candidates has
+// capacity, and returns the first such candidate in order of preference. The wait ends
+// after timeout, or earlier when ctx is done.
+func (r *InstanceResource) waitForCapacity(ctx context.Context, candidates []instanceCandidateModel, timeout time.Duration, pollInterval time.Duration) (instanceCandidateModel, error) {
+	stateConf := &retry.StateChangeConf{
+		Pending: []string{"unavailable"},
+		Target:  []string{"available"},
+		Refresh: func() (interface{}, string, error) {
+			instanceTypes, err := r.instanceTypes.List(lambdalabs.WithoutCache(ctx))
+			if err != nil {
+				return nil, "", fmt.Errorf("unable to read available instance types: %w", err)
+			}
+			for _, candidate := range candidates {
+				instanceType := candidate.InstanceType.ValueString()
+				instanceAvailability, ok := instanceTypes[instanceType]
+				if !ok {
+					return nil, "", fmt.Errorf("instance type %s not found in available instance types", instanceType)
+				}
+				for _, availableRegion := range instanceAvailability.RegionsWithCapacityAvailable {
+					if availableRegion.Name == candidate.Region.ValueString() {
+						return candidate, "available", nil
+					}
+				}
+			}
+			tflog.Debug(ctx, "waiting for capacity", map[string]interface{}{"candidates": describeCandidates(candidates)})
+			return candidates, "unavailable", nil
+		},
+		Timeout:      timeout,
+		PollInterval: pollInterval,
 	}
 
-	response, err := r.client.LaunchInstanceWithResponse(ctx, body)
+	result, err := stateConf.WaitForStateContext(ctx)
 	if err != nil {
-		resp.Diagnostics.AddError("HTTP Client Error", fmt.Sprintf("Unable to create instance, got error: %s", err))
+		return instanceCandidateModel{}, fmt.Errorf("no capacity available for %s: %w", strings.Join(describeCandidates(candidates), ", "), err)
+	}
+	candidate, ok := result.(instanceCandidateModel)
+	if !ok {
+		return instanceCandidateModel{}, fmt.Errorf("unexpected result type %T while waiting for capacity", result)
+	}
+	tflog.Info(ctx, "Found capacity", map[string]interface{}{"instance_type": candidate.InstanceType.ValueString(), "region": candidate.Region.ValueString()})
+	return candidate, nil
+}
+
+// describeCandidates formats candidates as "instance_type in region" for messages.
+func describeCandidates(candidates []instanceCandidateModel) []string {
+	descriptions := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		descriptions = append(descriptions, fmt.Sprintf("%s in %s", candidate.InstanceType.ValueString(), candidate.Region.ValueString()))
+	}
+	return descriptions
+}
+
+// requiresReplaceIfLaunchedCandidateRemoved requires replacement only when the instance type
+// and region that were launched no longer appear in the planned candidates, so that reordering
+// or extending the list does not replace a running instance.
+func requiresReplaceIfLaunchedCandidateRemoved(ctx context.Context, req planmodifier.ListRequest, resp *listplanmodifier.RequiresReplaceIfFuncResponse) {
+	if req.PlanValue.IsNull() || req.PlanValue.IsUnknown() {
+		// Switching to instance_type and region is covered by their own plan modifiers
 		return
 	}
 
-	if response.StatusCode() != 200 {
-		resp.Diagnostics.AddError(
-			fmt.Sprintf("Failed to create instance. HTTP Status Code: %d", response.StatusCode()),
-			fmt.Sprintf("Unable to create instance, got error: %s", response.Body),
-		)
+	var instanceType types.String
+	var region types.String
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("instance_type"), &instanceType)...)
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("region"), &region)...)
+
+	var candidates []instanceCandidateModel
+	resp.Diagnostics.Append(req.PlanValue.ElementsAs(ctx, &candidates, true)...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	if response.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Failed to create instance (no HTTP 200 response body)",
-			fmt.Sprintf("Unable to create instance, got error: %s", response.Body),
-		)
+	for _, candidate := range candidates {
+		if candidate.InstanceType.Equal(instanceType) && candidate.Region.Equal(region) {
+			return
+		}
+	}
+	resp.RequiresReplace = true
+}
+
+// requiresReplaceIfQuantityDecreased requires replacement only when quantity decreases, since
+// Update launches the missing instances when it increases.
+func requiresReplaceIfQuantityDecreased(ctx context.Context, req planmodifier.Int64Request, resp *int64planmodifier.RequiresReplaceIfFuncResponse) {
+	if req.PlanValue.IsUnknown() || req.StateValue.IsNull() {
 		return
 	}
+	resp.RequiresReplace = req.PlanValue.ValueInt64() < req.StateValue.ValueInt64()
+}
 
-	InstanceIDs := response.JSON200.Data.InstanceIds
+// waitForInstanceActive polls the instance until its status is active. It fails early
+// when the instance becomes unhealthy or is terminated, since it will never become active.
+// The wait is bounded by the deadline of ctx.
+func (r *InstanceResource) waitForInstanceActive(ctx context.Context, id string, pollInterval time.Duration) (*lambdalabs.Instance, error) {
+	timeout := defaultInstanceCreateTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
 
-	if len(InstanceIDs) == 1 {
-		tflog.Trace(ctx, "created new instance", map[string]interface{}{"id": InstanceIDs[0]})
-		data.ID = types.StringValue(response.JSON200.Data.InstanceIds[0])
-	} else {
-		tflog.Trace(ctx, "created new instances", map[string]interface{}{"ids": InstanceIDs})
+	stateConf := &retry.StateChangeConf{
+		Pending: []string{string(lambdalabs.InstanceStatusBooting)},
+		Target:  []string{string(lambdalabs.InstanceStatusActive)},
+		Refresh: func() (interface{}, string, error) {
+			instance, err := r.instances.Get(ctx, id)
+			if lambdalabs.IsNotFound(err) {
+				// A freshly launched instance may not be visible yet
+				return nil, "", nil
+			}
+			if err != nil {
+				return nil, "", fmt.Errorf("unable to read instance %s: %w", id, err)
+			}
+			switch instance.Status {
+			case lambdalabs.InstanceStatusUnhealthy, lambdalabs.InstanceStatusTerminating, lambdalabs.InstanceStatusTerminated:
+				return nil, "", fmt.Errorf("instance %s is %s", id, instance.Status)
+			}
+			tflog.Trace(ctx, "waiting for instance to become active", map[string]interface{}{"id": id, "status": instance.Status})
+			return &instance, string(instance.Status), nil
+		},
+		Timeout:      timeout,
+		PollInterval: pollInterval,
 	}
 
-	// Save data into Terraform state
-	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	result, err := stateConf.WaitForStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instance, ok := result.(*lambdalabs.Instance)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type %T while waiting for instance %s", result, id)
+	}
+	return instance, nil
 }
 
 func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
@@ -228,70 +887,263 @@ func (r *InstanceResource) Read(ctx context.Context, req resource.ReadRequest, r
 		return
 	}
 	tflog.Trace(ctx, fmt.Sprintf("reading current instance state %s", state.ID))
-	response, err := r.client.ListInstancesWithResponse(ctx)
+	instanceList, err := r.instances.List(ctx)
 	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to read instances %s, got error: %s", state.ID, err))
+		resp.Diagnostics.Append(apiErrorDiagnostics(fmt.Sprintf("Unable to read instances %s", state.ID), err, nil)...)
 		return
 	}
-	if response.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Failed to read instances (no HTTP 200 response body)",
-			fmt.Sprintf("Unable to read instances %s, got response: %s", state.ID, response.Body),
-		)
+
+	var instances = make(map[string]lambdalabs.Instance)
+	for _, instance := range instanceList {
+		instances[instance.Id] = instance
+	}
+
+	ids, diags := instanceIDsFromModel(ctx, state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	var instances = make(map[string]InstanceDataSourceModel)
-	for _, instance := range response.JSON200.Data {
-		instances[instance.Id] = InstanceDataSourceModel{
-			ID:       types.StringValue(instance.Id),
-			Hostname: types.StringPointerValue(instance.Hostname),
-			Ip:       types.StringPointerValue(instance.Ip),
-			Name:     types.StringPointerValue(instance.Name),
-			Region: &RegionModel{
-				Name:        types.StringValue(instance.Region.Name),
-				Description: types.StringValue(instance.Region.Description),
-			},
-			FileSystemNames: makeTfStringList(instance.FileSystemNames),
-			InstanceType: &InstanceTypeModel{
-				Description:       types.StringValue(instance.InstanceType.Description),
-				Name:              types.StringValue(instance.InstanceType.Name),
-				PriceCentsPerHour: types.Int64Value(int64(instance.InstanceType.PriceCentsPerHour)),
-				Specs: InstanceSpecsModel{
-					MemoryGib:  types.Int64Value(int64(instance.InstanceType.Specs.MemoryGib)),
-					StorageGib: types.Int64Value(int64(instance.InstanceType.Specs.StorageGib)),
-					Vcpus:      types.Int64Value(int64(instance.InstanceType.Specs.Vcpus)),
-				},
-			},
-			JupyterToken: types.StringPointerValue(instance.JupyterToken),
-			JupyterUrl:   types.StringPointerValue(instance.JupyterUrl),
-			SshKeyNames:  makeTfStringList(instance.SshKeyNames),
-			Status:       types.StringValue(string(instance.Status)),
+	// Instances terminated out of band drop out of ids, so that Terraform plans to launch them
+	// again, or to re-create the resource once none is left. quantity is kept as configured.
+	liveIDs := make([]string, 0)
+	for _, id := range ids {
+		instance, ok := instances[id]
+		if !ok || instance.Status == lambdalabs.InstanceStatusTerminating || instance.Status == lambdalabs.InstanceStatusTerminated {
+			tflog.Warn(ctx, "Instance no longer exists", map[string]interface{}{"id": id})
+			continue
 		}
+		liveIDs = append(liveIDs, id)
+	}
+	if len(liveIDs) == 0 {
+		tflog.Warn(ctx, "Removing instances from state", map[string]interface{}{"ids": ids})
+		resp.State.RemoveResource(ctx)
+		return
 	}
 
-	instance, ok := instances[state.ID.ValueString()]
-	if ok {
-		state.Name = instance.Name
-		state.RegionName = instance.Region.Name
-		state.FileSystemNames = instance.FileSystemNames
-		state.InstanceTypeName = instance.InstanceType.Name
-		state.SshKeyNames = instance.SshKeyNames
-	} else {
-		resp.Diagnostics.AddError(
-			"Failed to read instance",
-			fmt.Sprintf("Unable to read instance %s, got error: %s", state.ID, response.Body),
-		)
+	state.IDs, diags = types.SetValueFrom(ctx, types.StringType, liveIDs)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
+	if !containsString(liveIDs, state.ID.ValueString()) {
+		state.ID = types.StringValue(liveIDs[0])
+	}
+
+	instance := instances[state.ID.ValueString()]
+	state.Name = types.StringPointerValue(instance.Name)
+	if instance.Region != nil {
+		state.RegionName = types.StringValue(instance.Region.Name)
+	}
+	if instance.InstanceType != nil {
+		state.InstanceTypeName = types.StringValue(instance.InstanceType.Name)
+	}
+	state.SshKeyNames = makeTfStringList(instance.SshKeyNames)
+	if len(instance.FileSystemNames) > 0 || state.FileSystemNames != nil {
+		state.FileSystemNames = makeTfStringList(instance.FileSystemNames)
+	}
+	setInstanceComputedAttributes(&state, instance)
 
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
 }
 
 func (r *InstanceResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	// TODO: support in-place updates when the API supports it
-	resp.Diagnostics.AddError("Update not supported", "Lambda Labs Instance resource does not support updates. please report this issue to the provider developers.")
+	var data InstanceResourceModel
+	var state InstanceResourceModel
+
+	// Read Terraform plan and prior state data into the models
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Every attribute describing the instance itself requires replacement, so apart from a
+	// larger quantity and restart_triggers only provider-side settings (timeouts,
+	// capacity_timeout, poll_interval, candidates) end up here.
+	pollInterval, err := parseDuration(data.PollInterval, defaultPollInterval)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("poll_interval"), "Invalid poll_interval", err.Error())
+	}
+	ids, diags := instanceIDsFromModel(ctx, state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// ids is short of quantity when it increased or when Read dropped instances terminated out
+	// of band, and the missing instances are launched without replacing the others.
+	if missing := int(data.Quantity.ValueInt64()) - len(ids); missing > 0 {
+		launched, diags := r.launchMissingInstances(ctx, &data, state, missing, pollInterval)
+		resp.Diagnostics.Append(diags...)
+		// Only the instances that exist are saved, so that after a failed launch ids is still
+		// short of quantity and the rest are launched on the next apply
+		all := append(append([]string{}, ids...), launched...)
+		data.IDs, diags = types.SetValueFrom(ctx, types.StringType, all)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			setUnknownAttributesFromState(&data, state)
+			resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			return
+		}
+	}
+
+	// Only changing existing triggers restarts the instances, so that neither setting them for
+	// the first time, e.g. after an import, nor removing them does
+	if data.RestartTriggers.IsNull() || state.RestartTriggers.IsNull() || data.RestartTriggers.Equal(state.RestartTriggers) {
+		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+		return
+	}
+
+	updateTimeout, diags := data.Timeouts.Update(ctx, defaultInstanceUpdateTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	restarted, err := r.instances.Restart(ctx, ids)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Failed to restart instance", err, nil)...)
+		return
+	}
+	tflog.Trace(ctx, "restarted instances", map[string]interface{}{"ids": ids})
+
+	instances, err := r.waitForInstancesRestarted(ctx, ids, restarted, pollInterval)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Instance failed to restart",
+			fmt.Sprintf("Instances %s were restarted but were not seen booting and active again, got error: %s", ids, err),
+		)
+		return
+	}
+	if instance, ok := instances[data.ID.ValueString()]; ok {
+		setInstanceComputedAttributes(&data, instance)
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// launchMissingInstances launches count more instances like the ones in state, within the
+// create timeout, and waits for them to become active. It returns the IDs of the instances it
+// launched, even when they fail to become active.
+func (r *InstanceResource) launchMissingInstances(ctx context.Context, data *InstanceResourceModel, state InstanceResourceModel, count int, pollInterval time.Duration) ([]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	createTimeout, timeoutDiags := data.Timeouts.Create(ctx, defaultInstanceCreateTimeout)
+	diags.Append(timeoutDiags...)
+	capacityTimeout, err := parseDuration(data.CapacityTimeout, defaultCapacityTimeout)
+	if err != nil {
+		diags.AddAttributeError(path.Root("capacity_timeout"), "Invalid capacity_timeout", err.Error())
+	}
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, createTimeout)
+	defer cancel()
+
+	// The instances are launched with the instance type and region of the others, even when
+	// they came from candidates
+	candidates := []instanceCandidateModel{{InstanceType: state.InstanceTypeName, Region: state.RegionName}}
+	launched, launchDiags := r.launchInstances(ctx, data, candidates, count, capacityTimeout, pollInterval)
+	diags.Append(launchDiags...)
+	if launchDiags.HasError() {
+		if len(launched) > 0 {
+			diags.AddError(
+				"Failed to create all instances",
+				fmt.Sprintf("Only %d of %d missing instances were launched: %s", len(launched), count, launched),
+			)
+		}
+		return launched, diags
+	}
+	tflog.Trace(ctx, "launched missing instances", map[string]interface{}{"ids": launched})
+
+	for _, id := range launched {
+		if _, err := r.waitForInstanceActive(ctx, id, pollInterval); err != nil {
+			diags.AddError(
+				"Instance failed to become active",
+				fmt.Sprintf("Instance %s was launched but did not become active, got error: %s", id, err),
+			)
+			return launched, diags
+		}
+	}
+	return launched, diags
+}
+
+// waitForInstancesRestarted polls the instance list until every instance in ids has left the
+// active state after a restart and is active again, and returns the instances by ID. The API
+// may keep reporting an instance as active for a moment after the restart request, so a
+// restart only counts once the instance is seen booting, in the restart response or while
+// polling. The wait is bounded by the deadline of ctx.
+func (r *InstanceResource) waitForInstancesRestarted(ctx context.Context, ids []string, restarted []lambdalabs.Instance, pollInterval time.Duration) (map[string]lambdalabs.Instance, error) {
+	timeout := defaultInstanceUpdateTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	booted := make(map[string]bool)
+	for _, instance := range restarted {
+		if instance.Status != lambdalabs.InstanceStatusActive {
+			booted[instance.Id] = true
+		}
+	}
+
+	stateConf := &retry.StateChangeConf{
+		Pending: []string{string(lambdalabs.InstanceStatusBooting)},
+		Target:  []string{string(lambdalabs.InstanceStatusActive)},
+		Refresh: func() (interface{}, string, error) {
+			instances, err := r.instances.List(lambdalabs.WithoutCache(ctx))
+			if err != nil {
+				return nil, "", fmt.Errorf("unable to list instances: %w", err)
+			}
+			byID := make(map[string]lambdalabs.Instance)
+			for _, instance := range instances {
+				byID[instance.Id] = instance
+			}
+
+			waiting := make([]string, 0)
+			for _, id := range ids {
+				instance, ok := byID[id]
+				if !ok {
+					return nil, "", fmt.Errorf("instance %s no longer exists", id)
+				}
+				switch instance.Status {
+				case lambdalabs.InstanceStatusActive:
+					if !booted[id] {
+						waiting = append(waiting, id)
+					}
+				case lambdalabs.InstanceStatusTerminating, lambdalabs.InstanceStatusTerminated:
+					return nil, "", fmt.Errorf("instance %s is %s", id, instance.Status)
+				default:
+					booted[id] = true
+					waiting = append(waiting, id)
+				}
+			}
+			if len(waiting) > 0 {
+				tflog.Trace(ctx, "waiting for instances to restart", map[string]interface{}{"ids": waiting})
+				return byID, string(lambdalabs.InstanceStatusBooting), nil
+			}
+			return byID, string(lambdalabs.InstanceStatusActive), nil
+		},
+		Timeout:      timeout,
+		PollInterval: pollInterval,
+	}
+
+	result, err := stateConf.WaitForStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instances, ok := result.(map[string]lambdalabs.Instance)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type %T while waiting for instances %s", result, ids)
+	}
+	return instances, nil
 }
 
 func (r *InstanceResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
@@ -304,54 +1156,264 @@ func (r *InstanceResource) Delete(ctx context.Context, req resource.DeleteReques
 		return
 	}
 
-	instanceId := data.ID.ValueString()
-	body := lambdalabs.TerminateInstanceJSONRequestBody{
-		InstanceIds: []string{
-			instanceId,
-		},
+	deleteTimeout, diags := data.Timeouts.Delete(ctx, defaultInstanceDeleteTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	tflog.Debug(ctx, fmt.Sprintf("terminating instances %s", data.ID))
-	response, err := r.client.TerminateInstanceWithResponse(ctx, body)
+	pollInterval, err := parseDuration(data.PollInterval, defaultPollInterval)
 	if err != nil {
-		resp.Diagnostics.AddError("HTTP Client Error", fmt.Sprintf("Unable to delete instances %s, got error: %s", data.ID, err))
+		resp.Diagnostics.AddAttributeError(path.Root("poll_interval"), "Invalid poll_interval", err.Error())
 		return
 	}
-	if response.StatusCode() != 200 {
-		resp.Diagnostics.AddError(
-			"HTTP Client Error, HTTP Status Code: %d",
-			fmt.Sprintf("Unable to delete instances %s, got error: %s", data.ID, response.Body),
-		)
+
+	ctx, cancel := context.WithTimeout(ctx, deleteTimeout)
+	defer cancel()
+
+	ids, diags := instanceIDsFromModel(ctx, data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	if response.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Failed to delete instances (no HTTP 200 response body)",
-			fmt.Sprintf("Unable to delete instances %s, got error: %s", data.ID, response.Body),
-		)
+
+	statuses, err := r.instanceStatuses(ctx, ids)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to delete instances", fmt.Sprintf("Unable to delete instances %s, got error: %s", data.ID, err))
 		return
 	}
+	instanceIds := make([]string, 0)
+	// Instances that are already terminating are only waited for, the API may refuse to
+	// terminate them again
+	toTerminate := make([]string, 0)
+	for _, id := range ids {
+		status, ok := statuses[id]
+		if !ok || status == lambdalabs.InstanceStatusTerminated {
+			continue
+		}
+		instanceIds = append(instanceIds, id)
+		if status != lambdalabs.InstanceStatusTerminating {
+			toTerminate = append(toTerminate, id)
+		}
+	}
+	if len(instanceIds) == 0 {
+		tflog.Info(ctx, "Instances already terminated", map[string]interface{}{"ids": ids})
+		return
+	}
+
+	if len(toTerminate) > 0 {
+		tflog.Debug(ctx, fmt.Sprintf("terminating instances %s", toTerminate))
+		terminated, err := r.instances.Terminate(ctx, toTerminate)
+		var apiErr *lambdalabs.APIError
+		if errors.As(err, &apiErr) {
+			// The instances may have disappeared between listing and terminating them
+			if live, listErr := r.liveInstanceIDs(ctx, toTerminate); listErr == nil && len(live) == 0 {
+				tflog.Info(ctx, "Instances already terminated", map[string]interface{}{"ids": toTerminate})
+				err = nil
+			}
+		}
+		if err != nil {
+			resp.Diagnostics.Append(apiErrorDiagnostics(fmt.Sprintf("Failed to delete instances %s", toTerminate), err, nil)...)
+			return
+		}
 
-	terminated := response.JSON200.Data.TerminatedInstances
-	terminatedIDs := make([]string, 0)
-	for _, instance := range terminated {
-		terminatedIDs = append(terminatedIDs, instance.Id)
+		terminatedIDs := make([]string, 0)
+		for _, instance := range terminated {
+			terminatedIDs = append(terminatedIDs, instance.Id)
+		}
+		tflog.Debug(ctx, "Terminate request accepted", map[string]interface{}{"ids": terminatedIDs})
 	}
 
-	if len(terminatedIDs) == 0 {
+	err = r.waitForInstancesTerminated(ctx, instanceIds, pollInterval)
+	if err != nil {
 		resp.Diagnostics.AddError(
 			"Failed to delete instances",
-			fmt.Sprintf("Unable to delete instances %s, got error: %s", data.ID, response.Body),
+			fmt.Sprintf("Instances %s did not finish terminating, got error: %s", instanceIds, err),
 		)
+		return
 	}
 
-	if len(terminatedIDs) == 1 {
-		tflog.Info(ctx, "Terminated instance", map[string]interface{}{"id": terminatedIDs[0]})
+	if len(instanceIds) == 1 {
+		tflog.Info(ctx, "Terminated instance", map[string]interface{}{"id": instanceIds[0]})
 	} else {
-		tflog.Info(ctx, "Terminated instances", map[string]interface{}{"ids": terminatedIDs})
+		tflog.Info(ctx, "Terminated instances", map[string]interface{}{"ids": instanceIds})
 	}
 }
 
+// setInstanceComputedAttributes copies the attributes reported by the API into the resource model.
+func setInstanceComputedAttributes(data *InstanceResourceModel, instance lambdalabs.Instance) {
+	data.Hostname = types.StringPointerValue(instance.Hostname)
+	data.Ip = types.StringPointerValue(instance.Ip)
+	data.JupyterToken = types.StringPointerValue(instance.JupyterToken)
+	data.JupyterUrl = types.StringPointerValue(instance.JupyterUrl)
+	data.Status = types.StringValue(string(instance.Status))
+}
+
+// setUnknownAttributesFromState replaces the attributes left unknown by the plan with their
+// prior values, for saving the state of an update that failed part way.
+func setUnknownAttributesFromState(data *InstanceResourceModel, state InstanceResourceModel) {
+	for _, attribute := range []struct {
+		planned *types.String
+		prior   types.String
+	}{
+		{&data.ID, state.ID},
+		{&data.InstanceTypeName, state.InstanceTypeName},
+		{&data.RegionName, state.RegionName},
+		{&data.Hostname, state.Hostname},
+		{&data.Ip, state.Ip},
+		{&data.JupyterToken, state.JupyterToken},
+		{&data.JupyterUrl, state.JupyterUrl},
+		{&data.Status, state.Status},
+		{&data.CapacityTimeout, state.CapacityTimeout},
+		{&data.PollInterval, state.PollInterval},
+	} {
+		if attribute.planned.IsUnknown() {
+			*attribute.planned = attribute.prior
+		}
+	}
+}
+
+// instanceIDsFromModel returns the IDs of every instance tracked by the resource.
+func instanceIDsFromModel(ctx context.Context, data InstanceResourceModel) ([]string, diag.Diagnostics) {
+	var ids []string
+	if data.IDs.IsNull() || data.IDs.IsUnknown() {
+		if data.ID.IsNull() || data.ID.IsUnknown() {
+			return ids, nil
+		}
+		return []string{data.ID.ValueString()}, nil
+	}
+	diags := data.IDs.ElementsAs(ctx, &ids, false)
+	return ids, diags
+}
+
+// instanceStatuses returns the status of each instance in ids that is still listed. It is used
+// while polling, so it always asks the API instead of reusing a cached instance list.
+func (r *InstanceResource) instanceStatuses(ctx context.Context, ids []string) (map[string]lambdalabs.InstanceStatus, error) {
+	instances, err := r.instances.List(lambdalabs.WithoutCache(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list instances: %w", err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	statuses := make(map[string]lambdalabs.InstanceStatus)
+	for _, instance := range instances {
+		if wanted[instance.Id] {
+			statuses[instance.Id] = instance.Status
+		}
+	}
+	return statuses, nil
+}
+
+// liveInstanceIDs returns the subset of ids that still exist and are not terminated.
+func (r *InstanceResource) liveInstanceIDs(ctx context.Context, ids []string) ([]string, error) {
+	statuses, err := r.instanceStatuses(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make([]string, 0)
+	for _, id := range ids {
+		status, ok := statuses[id]
+		if ok && status != lambdalabs.InstanceStatusTerminated {
+			live = append(live, id)
+		}
+	}
+	return live, nil
+}
+
+// waitForInstancesTerminated polls the instance list until every instance in ids is
+// terminated or no longer listed. The wait is bounded by the deadline of ctx.
+func (r *InstanceResource) waitForInstancesTerminated(ctx context.Context, ids []string, pollInterval time.Duration) error {
+	timeout := defaultInstanceDeleteTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	stateConf := &retry.StateChangeConf{
+		Pending: []string{string(lambdalabs.InstanceStatusTerminating)},
+		Target:  []string{string(lambdalabs.InstanceStatusTerminated)},
+		Refresh: func() (interface{}, string, error) {
+			live, err := r.liveInstanceIDs(ctx, ids)
+			if err != nil {
+				return nil, "", err
+			}
+			if len(live) > 0 {
+				tflog.Trace(ctx, "waiting for instances to terminate", map[string]interface{}{"ids": live})
+				return live, string(lambdalabs.InstanceStatusTerminating), nil
+			}
+			return live, string(lambdalabs.InstanceStatusTerminated), nil
+		},
+		Timeout:      timeout,
+		PollInterval: pollInterval,
+	}
+
+	_, err := stateConf.WaitForStateContext(ctx)
+	return err
+}
+
+// ImportState adopts an existing instance, identified either by its ID or by "name:<instance-name>".
 func (r *InstanceResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("ids"), req, resp)
+	instanceId := req.ID
+	if strings.HasPrefix(req.ID, instanceImportNamePrefix) {
+		instance, err := r.instances.FindByName(ctx, strings.TrimPrefix(req.ID, instanceImportNamePrefix))
+		if err != nil {
+			resp.Diagnostics.AddError("Failed to import instance", err.Error())
+			return
+		}
+		instanceId = instance.Id
+	}
+
+	instance, err := r.instances.Get(ctx, instanceId)
+	if lambdalabs.IsNotFound(err) {
+		resp.Diagnostics.AddError("Failed to import instance", fmt.Sprintf("Instance %s does not exist", instanceId))
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Failed to import instance", err, nil)...)
+		return
+	}
+
+	if instance.Status == lambdalabs.InstanceStatusTerminating || instance.Status == lambdalabs.InstanceStatusTerminated {
+		resp.Diagnostics.AddError("Failed to import instance", fmt.Sprintf("Instance %s is %s", instanceId, instance.Status))
+		return
+	}
+
+	data := InstanceResourceModel{
+		ID:              types.StringValue(instance.Id),
+		Name:            types.StringPointerValue(instance.Name),
+		SshKeyNames:     makeTfStringList(instance.SshKeyNames),
+		Quantity:        types.Int64Value(1),
+		CapacityTimeout: types.StringValue(defaultCapacityTimeoutValue),
+		PollInterval:    types.StringValue(defaultPollIntervalValue),
+		RestartTriggers: types.MapNull(types.StringType),
+	}
+	if instance.InstanceType != nil {
+		data.InstanceTypeName = types.StringValue(instance.InstanceType.Name)
+	}
+	if instance.Region != nil {
+		data.RegionName = types.StringValue(instance.Region.Name)
+	}
+	if len(instance.FileSystemNames) > 0 {
+		data.FileSystemNames = makeTfStringList(instance.FileSystemNames)
+	}
+	setInstanceComputedAttributes(&data, instance)
+
+	ids, diags := types.SetValueFrom(ctx, types.StringType, []string{instance.Id})
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	data.IDs = ids
+
+	// The timeouts block stays null, as it is when omitted from the configuration
+	resp.Diagnostics.Append(resp.State.GetAttribute(ctx, path.Root("timeouts"), &data.Timeouts)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -0,0 +1,1151 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"terraform-provider-lambdalabs/internal/fakelambda"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+// fakeInstances serves instances from memory. Every Get advances the instance to the next of
+// its statuses, if any are left.
+type fakeInstances struct {
+	lambdalabs.InstancesService
+	instances map[string]lambdalabs.Instance
+	statuses  map[string][]lambdalabs.InstanceStatus
+}
+
+func (f *fakeInstances) List(ctx context.Context) ([]lambdalabs.Instance, error) {
+	instances := make([]lambdalabs.Instance, 0, len(f.instances))
+	for _, instance := range f.instances {
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+func (f *fakeInstances) Get(ctx context.Context, id string) (lambdalabs.Instance, error) {
+	instance, ok := f.instances[id]
+	if !ok {
+		return lambdalabs.Instance{}, fmt.Errorf("instance %s: %w", id, lambdalabs.ErrNotFound)
+	}
+	if statuses := f.statuses[id]; len(statuses) > 0 {
+		instance.Status = statuses[0]
+		f.statuses[id] = statuses[1:]
+		f.instances[id] = instance
+	}
+	return instance, nil
+}
+
+func TestInstanceResourceLiveInstanceIDs(t *testing.T) {
+	r := &InstanceResource{instances: &fakeInstances{instances: map[string]lambdalabs.Instance{
+		"booting":     {Id: "booting", Status: lambdalabs.InstanceStatusBooting},
+		"active":      {Id: "active", Status: lambdalabs.InstanceStatusActive},
+		"terminating": {Id: "terminating", Status: lambdalabs.InstanceStatusTerminating},
+		"terminated":  {Id: "terminated", Status: lambdalabs.InstanceStatusTerminated},
+	}}}
+
+	live, err := r.liveInstanceIDs(context.Background(), []string{"booting", "active", "terminating", "terminated", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"booting", "active", "terminating"}
+	if !reflect.DeepEqual(live, expected) {
+		t.Errorf("expected %v, got %v", expected, live)
+	}
+}
+
+func TestInstanceResourceWaitForInstanceActive(t *testing.T) {
+	fake := &fakeInstances{
+		instances: map[string]lambdalabs.Instance{
+			"healthy":   {Id: "healthy"},
+			"unhealthy": {Id: "unhealthy"},
+		},
+		statuses: map[string][]lambdalabs.InstanceStatus{
+			"healthy":   {lambdalabs.InstanceStatusBooting, lambdalabs.InstanceStatusBooting, lambdalabs.InstanceStatusActive},
+			"unhealthy": {lambdalabs.InstanceStatusBooting, lambdalabs.InstanceStatusUnhealthy},
+		},
+	}
+	r := &InstanceResource{instances: fake}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	instance, err := r.waitForInstanceActive(ctx, "healthy", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status != lambdalabs.InstanceStatusActive {
+		t.Errorf("expected an active instance, got %s", instance.Status)
+	}
+
+	if _, err := r.waitForInstanceActive(ctx, "unhealthy", time.Millisecond); err == nil {
+		t.Errorf("expected an error for an unhealthy instance")
+	}
+}
+
+func TestInstanceResourceCreateWaitsForActive(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.BootPolls = 3
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	state := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+
+	if calls := server.Calls(fakelambda.OperationGetInstance); calls < 4 {
+		t.Errorf("expected the instance to be polled until active, got %d calls", calls)
+	}
+	instance, ok := server.Instance(testString(t, state, "id"))
+	if !ok {
+		t.Fatalf("instance %s not found in the API", testString(t, state, "id"))
+	}
+	if status := testString(t, state, "status"); status != string(lambdalabs.InstanceStatusActive) {
+		t.Errorf("expected status active, got %q", status)
+	}
+	for name, expected := range map[string]*string{
+		"ip":            instance.Ip,
+		"hostname":      instance.Hostname,
+		"jupyter_url":   instance.JupyterUrl,
+		"jupyter_token": instance.JupyterToken,
+	} {
+		if actual := testString(t, state, name); expected == nil || actual != *expected {
+			t.Errorf("expected %s %v, got %q", name, expected, actual)
+		}
+	}
+}
+
+func TestInstanceResourceCreateUnhealthy(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.BootPolls = 3
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	// The instance breaks while booting
+	p := newTestProviderWithHook(t, server, func(req *http.Request) {
+		if id := strings.TrimPrefix(req.URL.Path, "/api/v1/instances/"); req.Method == http.MethodGet && id != req.URL.Path {
+			server.SetInstanceStatus(id, lambdalabs.InstanceStatusUnhealthy)
+		}
+	})
+
+	state, diags := p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), testInstanceResourceConfig(nil))
+	requireError(t, diags, `(?s)Instance failed to become active.*is unhealthy`)
+
+	// The instance is kept in state, so that Terraform taints it instead of losing track of it
+	id := testString(t, state, "id")
+	if _, ok := server.Instance(id); !ok {
+		t.Fatalf("expected the launched instance %q to be kept in state", id)
+	}
+	if status := testString(t, state, "status"); status != "" {
+		t.Errorf("expected no status until the instance is active, got %q", status)
+	}
+}
+
+func TestInstanceResourceCreateCapacityTimeout(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.SetCapacity("gpu_1x_a10", "us-east-1", 0)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	_, diags := p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), testInstanceResourceConfig(map[string]interface{}{
+		"capacity_timeout": "100ms",
+	}))
+	requireWarning(t, diags, "No capacity")
+	requireError(t, diags, `(?s)Instance type unavailable.*no capacity available for gpu_1x_a10 in us-east-1`)
+	if calls := server.Calls(fakelambda.OperationLaunchInstance); calls != 0 {
+		t.Errorf("expected no launch without capacity, got %d calls", calls)
+	}
+}
+
+func TestInstanceResourceCreateWaitsForCapacity(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.SetCapacity("gpu_1x_a10", "us-east-1", 0)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	// Capacity frees up after a few polls
+	polls := 0
+	p := newTestProviderWithHook(t, server, func(req *http.Request) {
+		if req.URL.Path == "/api/v1/instance-types" {
+			polls++
+			if polls == 4 {
+				server.SetCapacity("gpu_1x_a10", "us-east-1", 1)
+			}
+		}
+	})
+
+	state := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+	if polls < 4 {
+		t.Errorf("expected capacity to be polled until available, got %d polls", polls)
+	}
+	if status := testString(t, state, "status"); status != string(lambdalabs.InstanceStatusActive) {
+		t.Errorf("expected status active, got %q", status)
+	}
+}
+
+func TestInstanceResourceCreateTimeout(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.BootPolls = 1000
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	state, diags := p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), testInstanceResourceConfig(map[string]interface{}{
+		"timeouts": map[string]interface{}{"create": "200ms"},
+	}))
+	requireError(t, diags, `(?s)Instance failed to become active.*timeout`)
+	if _, ok := server.Instance(testString(t, state, "id")); !ok {
+		t.Errorf("expected the launched instance to be kept in state, got %q", testString(t, state, "id"))
+	}
+}
+
+func TestInstanceResourceInvalidDurations(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	for _, attribute := range []string{"capacity_timeout", "poll_interval"} {
+		for _, value := range []string{"soon", "0s"} {
+			_, diags := p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), testInstanceResourceConfig(map[string]interface{}{
+				attribute: value,
+			}))
+			requireError(t, diags, "Invalid duration")
+		}
+	}
+	if calls := server.Calls(fakelambda.OperationLaunchInstance); calls != 0 {
+		t.Errorf("expected invalid durations to be rejected before launching, got %d calls", calls)
+	}
+}
+
+func TestInstanceResourceDeleteWaitsForTerminated(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.TerminatePolls = 3
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	state := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+	id := testString(t, state, "id")
+
+	polls := server.Calls(fakelambda.OperationListInstances)
+	state, diags := p.apply("lambdalabs_instance", state, nil)
+	requireNoErrors(t, "Delete", diags)
+	if !state.IsNull() {
+		t.Errorf("expected the instance to be removed from state, got %s", state)
+	}
+	if polls = server.Calls(fakelambda.OperationListInstances) - polls; polls < 4 {
+		t.Errorf("expected the instance to be polled until terminated, got %d polls", polls)
+	}
+	if instance, ok := server.Instance(id); ok && instance.Status != lambdalabs.InstanceStatusTerminated {
+		t.Errorf("expected instance %s to be terminated, got %s", id, instance.Status)
+	}
+}
+
+func TestInstanceResourceDeleteAlreadyTerminated(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	for _, gone := range []bool{false, true} {
+		state := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+		server.SetInstanceStatus(testString(t, state, "id"), lambdalabs.InstanceStatusTerminated)
+		if gone {
+			// Listing the instance once as terminated removes it from the API
+			if _, diags := p.read("lambdalabs_instance", state); testHasErrors(diags) {
+				t.Fatal(testDescribeDiagnostics(diags))
+			}
+		}
+
+		_, diags := p.apply("lambdalabs_instance", state, nil)
+		requireNoErrors(t, "Delete", diags)
+	}
+	if calls := server.Calls(fakelambda.OperationTerminateInstance); calls != 0 {
+		t.Errorf("expected no terminate call for terminated instances, got %d", calls)
+	}
+}
+
+func TestInstanceResourceDeleteTerminating(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.TerminatePolls = 3
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	state := testCreateInstance(t, p, testInstanceResourceConfig(map[string]interface{}{"quantity": 2}))
+	ids := testStrings(t, state, "ids")
+
+	// An instance already terminating out of band is only waited for, while the other one is
+	// terminated
+	instances := testInstancesService(t, server)
+	if _, err := instances.Terminate(context.Background(), ids[:1]); err != nil {
+		t.Fatal(err)
+	}
+	calls := server.Calls(fakelambda.OperationTerminateInstance)
+	state, diags := p.apply("lambdalabs_instance", state, nil)
+	requireNoErrors(t, "Delete", diags)
+	if !state.IsNull() {
+		t.Errorf("expected the instances to be removed from state, got %s", state)
+	}
+	if calls = server.Calls(fakelambda.OperationTerminateInstance) - calls; calls != 1 {
+		t.Errorf("expected one terminate call, got %d", calls)
+	}
+	for _, id := range ids {
+		if instance, ok := server.Instance(id); ok && instance.Status != lambdalabs.InstanceStatusTerminated {
+			t.Errorf("expected instance %s to be terminated, got %s", id, instance.Status)
+		}
+	}
+
+	// Every instance terminating is not terminated again
+	state = testCreateInstance(t, p, testInstanceResourceConfig(nil))
+	if _, err := instances.Terminate(context.Background(), []string{testString(t, state, "id")}); err != nil {
+		t.Fatal(err)
+	}
+	calls = server.Calls(fakelambda.OperationTerminateInstance)
+	_, diags = p.apply("lambdalabs_instance", state, nil)
+	requireNoErrors(t, "Delete", diags)
+	if calls = server.Calls(fakelambda.OperationTerminateInstance) - calls; calls != 0 {
+		t.Errorf("expected no terminate call for a terminating instance, got %d", calls)
+	}
+}
+
+func TestInstanceResourceDeleteTimeout(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.TerminatePolls = 1000
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	state := testCreateInstance(t, p, testInstanceResourceConfig(map[string]interface{}{
+		"timeouts": map[string]interface{}{"delete": "200ms"},
+	}))
+
+	state, diags := p.apply("lambdalabs_instance", state, nil)
+	requireError(t, diags, `(?s)Failed to delete instances.*did not finish terminating`)
+	if state.IsNull() {
+		t.Error("expected the instance to be kept in state until it is terminated")
+	}
+}
+
+func TestInstanceResourceQuantity(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	config := testInstanceResourceConfig(map[string]interface{}{"quantity": 3})
+
+	state := testCreateInstance(t, p, config)
+	ids := testStrings(t, state, "ids")
+	if len(ids) != 3 {
+		t.Fatalf("expected 3 ids, got %v", ids)
+	}
+	if id := testString(t, state, "id"); !containsString(ids, id) {
+		t.Errorf("expected id %s to be one of ids %v", id, ids)
+	}
+	for _, id := range ids {
+		instance, ok := server.Instance(id)
+		if !ok || instance.Status != lambdalabs.InstanceStatusActive {
+			t.Errorf("expected instance %s to be active, got %v", id, instance.Status)
+		}
+	}
+	p.requireEmptyPlan("lambdalabs_instance", state, config)
+
+	_, diags := p.apply("lambdalabs_instance", state, nil)
+	requireNoErrors(t, "Delete", diags)
+	for _, id := range ids {
+		if instance, ok := server.Instance(id); ok && instance.Status != lambdalabs.InstanceStatusTerminated {
+			t.Errorf("expected instance %s to be terminated, got %s", id, instance.Status)
+		}
+	}
+}
+
+func TestInstanceResourceQuantityPartialLaunch(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.SetCapacity("gpu_1x_a10", "us-east-1", 2)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	state, diags := p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), testInstanceResourceConfig(map[string]interface{}{
+		"quantity":         3,
+		"capacity_timeout": "100ms",
+	}))
+	requireError(t, diags, `Only 2 of 3 instances were launched`)
+
+	// The launched instances are kept in state, so that Terraform taints them instead of losing track of them
+	ids := testStrings(t, state, "ids")
+	if len(ids) != 2 {
+		t.Fatalf("expected the 2 launched instances in state, got %v", ids)
+	}
+	for _, id := range ids {
+		if _, ok := server.Instance(id); !ok {
+			t.Errorf("instance %s not found in the API", id)
+		}
+	}
+}
+
+func TestInstanceResourceQuantityFailedScaleUp(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	created := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+	id := testString(t, created, "id")
+
+	server.SetCapacity("gpu_1x_a10", "us-east-1", 0)
+	config := testInstanceResourceConfig(map[string]interface{}{
+		"quantity":         2,
+		"capacity_timeout": "100ms",
+	})
+	state, diags := p.apply("lambdalabs_instance", created, config)
+	requireError(t, diags, "Instance type unavailable")
+
+	// The running instance stays in state, and the missing one is launched on the next apply
+	if ids := testStrings(t, state, "ids"); !reflect.DeepEqual(ids, []string{id}) {
+		t.Fatalf("expected ids [%s] after the failed scale up, got %v", id, ids)
+	}
+	if quantity := testAttribute(t, state, "quantity"); !quantity.Equal(tftypes.NewValue(tftypes.Number, big.NewFloat(2))) {
+		t.Errorf("expected quantity to stay 2 after the failed scale up, got %s", quantity)
+	}
+	planned, _ := p.plan("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	if ids := testAttribute(t, p.value(planned.PlannedState, p.resourceType("lambdalabs_instance")), "ids"); ids.IsKnown() {
+		t.Errorf("expected ids to be unknown while instances are missing, got %s", ids)
+	}
+
+	server.SetCapacity("gpu_1x_a10", "us-east-1", 1)
+	state, diags = p.apply("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Update", diags)
+	if ids := testStrings(t, state, "ids"); len(ids) != 2 || !containsString(ids, id) {
+		t.Fatalf("expected instance %s and a new instance, got %v", id, ids)
+	}
+	p.requireEmptyPlan("lambdalabs_instance", state, config)
+}
+
+func TestInstanceResourceQuantityWithFileSystem(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	server.AddFileSystem("datasets", "us-east-1")
+	p := newTestProvider(t, server)
+
+	_, diags := p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), testInstanceResourceConfig(map[string]interface{}{
+		"quantity":         2,
+		"filesystem_names": []string{"datasets"},
+	}))
+	requireError(t, diags, "Invalid quantity with filesystem_names")
+	if calls := server.Calls(fakelambda.OperationLaunchInstance); calls != 0 {
+		t.Errorf("expected the configuration to be rejected before launching, got %d calls", calls)
+	}
+
+	// A single instance can have the filesystem
+	testCreateInstance(t, p, testInstanceResourceConfig(map[string]interface{}{
+		"quantity":         1,
+		"filesystem_names": []string{"datasets"},
+	}))
+}
+
+func TestInstanceResourceReadTerminated(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	for _, status := range []lambdalabs.InstanceStatus{lambdalabs.InstanceStatusTerminating, lambdalabs.InstanceStatusTerminated} {
+		state := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+		server.SetInstanceStatus(testString(t, state, "id"), status)
+
+		state, diags := p.read("lambdalabs_instance", state)
+		requireNoErrors(t, "Read", diags)
+		if !state.IsNull() {
+			t.Errorf("expected a %s instance to be removed from state, got %s", status, state)
+		}
+	}
+
+	// Instances that no longer exist are removed too
+	state := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+	state = testWithAttribute(t, state, "id", "0920582c7ff041399e34823a0be62549")
+	state = testWithAttribute(t, state, "ids", []string{"0920582c7ff041399e34823a0be62549"})
+	state, diags := p.read("lambdalabs_instance", state)
+	requireNoErrors(t, "Read", diags)
+	if !state.IsNull() {
+		t.Errorf("expected a missing instance to be removed from state, got %s", state)
+	}
+}
+
+func TestInstanceResourceReadRefreshesAttributes(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	config := testInstanceResourceConfig(nil)
+	created := testCreateInstance(t, p, config)
+
+	// Out of band changes show up as drift
+	state := created
+	for name, value := range map[string]interface{}{
+		"name":          "renamed",
+		"ssh_key_names": []string{"other"},
+		"ip":            "192.0.2.1",
+		"hostname":      "192-0-2-1.cloud.lambdalabs.com",
+	} {
+		state = testWithAttribute(t, state, name, value)
+	}
+	server.SetInstanceStatus(testString(t, state, "id"), lambdalabs.InstanceStatusUnhealthy)
+
+	state, diags := p.read("lambdalabs_instance", state)
+	requireNoErrors(t, "Read", diags)
+	for _, name := range []string{"name", "ip", "hostname"} {
+		if expected, actual := testString(t, created, name), testString(t, state, name); actual != expected {
+			t.Errorf("expected %s to be refreshed to %q, got %q", name, expected, actual)
+		}
+	}
+	if sshKeyNames := testStrings(t, state, "ssh_key_names"); !reflect.DeepEqual(sshKeyNames, []string{"acceptance-test"}) {
+		t.Errorf("expected ssh_key_names to be refreshed, got %v", sshKeyNames)
+	}
+	if status := testString(t, state, "status"); status != string(lambdalabs.InstanceStatusUnhealthy) {
+		t.Errorf("expected status to be refreshed to unhealthy, got %q", status)
+	}
+}
+
+func TestInstanceResourcePartialLoss(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	config := testInstanceResourceConfig(map[string]interface{}{"quantity": 3})
+	state := testCreateInstance(t, p, config)
+	ids := testStrings(t, state, "ids")
+
+	// One of the instances is terminated outside of Terraform
+	server.SetInstanceStatus(ids[1], lambdalabs.InstanceStatusTerminated)
+	state, diags := p.read("lambdalabs_instance", state)
+	requireNoErrors(t, "Read", diags)
+	survivors := []string{ids[0], ids[2]}
+	if live := testStrings(t, state, "ids"); !reflect.DeepEqual(live, survivors) {
+		t.Fatalf("expected ids %v after the loss, got %v", survivors, live)
+	}
+	if quantity := testAttribute(t, state, "quantity"); !quantity.Equal(tftypes.NewValue(tftypes.Number, big.NewFloat(3))) {
+		t.Errorf("expected quantity to stay as configured, got %s", quantity)
+	}
+
+	planned, _ := p.plan("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	if len(planned.RequiresReplace) > 0 {
+		t.Fatalf("expected the surviving instances to be kept, got replacement for %v", planned.RequiresReplace)
+	}
+	if ids := testAttribute(t, p.value(planned.PlannedState, p.resourceType("lambdalabs_instance")), "ids"); ids.IsKnown() {
+		t.Errorf("expected ids to be unknown until the missing instance is launched, got %s", ids)
+	}
+
+	state, diags = p.apply("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Update", diags)
+	ids = testStrings(t, state, "ids")
+	if len(ids) != 3 || !containsString(ids, survivors[0]) || !containsString(ids, survivors[1]) {
+		t.Fatalf("expected the survivors %v and a new instance, got %v", survivors, ids)
+	}
+	for _, id := range ids {
+		if instance, ok := server.Instance(id); !ok || instance.Status != lambdalabs.InstanceStatusActive {
+			t.Errorf("expected instance %s to be active, got %v", id, instance.Status)
+		}
+	}
+	if calls := server.Calls(fakelambda.OperationTerminateInstance); calls != 0 {
+		t.Errorf("expected no instance to be terminated, got %d calls", calls)
+	}
+	p.requireEmptyPlan("lambdalabs_instance", state, config)
+
+	// A lower quantity replaces the instances
+	planned, _ = p.plan("lambdalabs_instance", state, testInstanceResourceConfig(map[string]interface{}{"quantity": 2}))
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	if len(planned.RequiresReplace) == 0 {
+		t.Error("expected a lower quantity to require replacement")
+	}
+}
+
+func TestInstanceResourceImport(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	server.AddFileSystem("datasets", "us-east-1")
+	p := newTestProvider(t, server)
+	config := testInstanceResourceConfig(map[string]interface{}{"filesystem_names": []string{"datasets"}})
+	created := testCreateInstance(t, p, config)
+	id := testString(t, created, "id")
+	// Import sets provider-side settings to their defaults
+	delete(config, "poll_interval")
+
+	for _, importID := range []string{id, "name:acceptance-test"} {
+		state, diags := p.importState("lambdalabs_instance", importID)
+		requireNoErrors(t, "Import "+importID, diags)
+		if imported := testString(t, state, "id"); imported != id {
+			t.Errorf("expected %s to import instance %s, got %s", importID, id, imported)
+		}
+		for _, name := range []string{"name", "instance_type", "region", "ip"} {
+			if expected, actual := testString(t, created, name), testString(t, state, name); actual != expected {
+				t.Errorf("expected imported %s %q, got %q", name, expected, actual)
+			}
+		}
+		if fileSystemNames := testStrings(t, state, "filesystem_names"); !reflect.DeepEqual(fileSystemNames, []string{"datasets"}) {
+			t.Errorf("expected imported filesystem_names [datasets], got %v", fileSystemNames)
+		}
+		p.requireEmptyPlan("lambdalabs_instance", state, config)
+	}
+}
+
+func TestInstanceResourceImportErrors(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	testCreateInstance(t, p, testInstanceResourceConfig(map[string]interface{}{"name": "twin"}))
+	testCreateInstance(t, p, testInstanceResourceConfig(map[string]interface{}{"name": "twin"}))
+	terminated := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+	server.SetInstanceStatus(testString(t, terminated, "id"), lambdalabs.InstanceStatusTerminating)
+
+	for importID, expected := range map[string]string{
+		"0920582c7ff041399e34823a0be62549": "Instance 0920582c7ff041399e34823a0be62549 does not exist",
+		"name:missing":                     `no instance named "missing"`,
+		"name:twin":                        `2 instances are named "twin"`,
+		testString(t, terminated, "id"):    "is terminating",
+	} {
+		_, diags := p.importState("lambdalabs_instance", importID)
+		requireError(t, diags, regexp.QuoteMeta(expected))
+	}
+}
+
+// testInstanceCandidates returns candidates for pairs of instance types and regions.
+func testInstanceCandidates(pairs ...string) []interface{} {
+	candidates := make([]interface{}, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		candidates = append(candidates, map[string]interface{}{"instance_type": pairs[i], "region": pairs[i+1]})
+	}
+	return candidates
+}
+
+func TestInstanceResourceCandidates(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	config := testInstanceResourceConfig(map[string]interface{}{
+		"instance_type": nil,
+		"region":        nil,
+		"candidates": testInstanceCandidates(
+			"gpu_8x_a100_80gb_sxm4", "us-east-1",
+			"gpu_1x_a100", "us-west-1",
+			"gpu_1x_a10", "us-east-1",
+		),
+	})
+
+	// The first candidate with capacity is launched
+	state := testCreateInstance(t, p, config)
+	if instanceType, region := testString(t, state, "instance_type"), testString(t, state, "region"); instanceType != "gpu_1x_a100" || region != "us-west-1" {
+		t.Errorf("expected gpu_1x_a100 in us-west-1 to be launched, got %s in %s", instanceType, region)
+	}
+	instance, ok := server.Instance(testString(t, state, "id"))
+	if !ok || instance.InstanceType.Name != "gpu_1x_a100" || instance.Region.Name != "us-west-1" {
+		t.Errorf("expected the API to have launched gpu_1x_a100 in us-west-1, got %+v", instance)
+	}
+	p.requireEmptyPlan("lambdalabs_instance", state, config)
+
+	// Reordering candidates keeps the instance, even though another candidate now comes first
+	config["candidates"] = testInstanceCandidates(
+		"gpu_1x_a10", "us-east-1",
+		"gpu_1x_a100", "us-west-1",
+	)
+	updated, diags := p.apply("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Update", diags)
+	if id := testString(t, updated, "id"); id != testString(t, state, "id") {
+		t.Errorf("expected instance %s to be kept, got %s", testString(t, state, "id"), id)
+	}
+	if instanceType := testString(t, updated, "instance_type"); instanceType != "gpu_1x_a100" {
+		t.Errorf("expected the launched instance type to be kept, got %s", instanceType)
+	}
+	p.requireEmptyPlan("lambdalabs_instance", updated, config)
+
+	// Removing the launched candidate replaces the instance
+	config["candidates"] = testInstanceCandidates("gpu_1x_a10", "us-east-1")
+	planned, _ := p.plan("lambdalabs_instance", updated, config)
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	if len(planned.RequiresReplace) == 0 {
+		t.Error("expected removing the launched candidate to require replacement")
+	}
+}
+
+func TestInstanceResourceCandidatesConflicts(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	_, diags := p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), testInstanceResourceConfig(map[string]interface{}{
+		"candidates": testInstanceCandidates("gpu_1x_a100", "us-west-1"),
+	}))
+	requireError(t, diags, "Conflicting instance_type and candidates")
+	requireError(t, diags, "Conflicting region and candidates")
+
+	_, diags = p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), testInstanceResourceConfig(map[string]interface{}{
+		"instance_type": nil,
+		"region":        nil,
+	}))
+	requireError(t, diags, "Missing instance_type")
+	requireError(t, diags, "Missing region")
+}
+
+func TestInstanceResourceRestartTriggers(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.BootPolls = 2
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	config := testInstanceResourceConfig(map[string]interface{}{
+		"quantity":         2,
+		"restart_triggers": map[string]string{"driver": "535"},
+	})
+	state := testCreateInstance(t, p, config)
+	ids := testStrings(t, state, "ids")
+
+	// Changing a trigger restarts every instance in place, and waits for them to be active
+	config["restart_triggers"] = map[string]string{"driver": "545"}
+	planned, _ := p.plan("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	if len(planned.RequiresReplace) > 0 {
+		t.Fatalf("expected an in-place restart, got replacement for %v", planned.RequiresReplace)
+	}
+	state, diags := p.apply("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Update", diags)
+	if calls := server.Calls(fakelambda.OperationRestartInstance); calls != 1 {
+		t.Errorf("expected the instances to be restarted once, got %d calls", calls)
+	}
+	if restarted := testStrings(t, state, "ids"); !reflect.DeepEqual(restarted, ids) {
+		t.Errorf("expected instances %v to be kept, got %v", ids, restarted)
+	}
+	for _, id := range ids {
+		if instance, ok := server.Instance(id); !ok || instance.Status != lambdalabs.InstanceStatusActive {
+			t.Errorf("expected instance %s to be active again, got %v", id, instance.Status)
+		}
+	}
+	if status := testString(t, state, "status"); status != string(lambdalabs.InstanceStatusActive) {
+		t.Errorf("expected status active, got %q", status)
+	}
+	p.requireEmptyPlan("lambdalabs_instance", state, config)
+
+	// Removing the triggers does not restart the instances
+	delete(config, "restart_triggers")
+	_, diags = p.apply("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Update", diags)
+	if calls := server.Calls(fakelambda.OperationRestartInstance); calls != 1 {
+		t.Errorf("expected no restart when the triggers are removed, got %d calls", calls)
+	}
+}
+
+func TestInstanceResourceRestartNotObserved(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	// Restarts complete before the response, so the instance is never seen booting
+	server.BootPolls = 0
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	config := testInstanceResourceConfig(map[string]interface{}{
+		"restart_triggers": map[string]string{"driver": "535"},
+		"timeouts":         map[string]interface{}{"update": "200ms"},
+	})
+	state := testCreateInstance(t, p, config)
+
+	// A restart that never shows up as a status change is an error once the update timeout
+	// expires, instead of being assumed to have completed
+	config["restart_triggers"] = map[string]string{"driver": "545"}
+	_, diags := p.apply("lambdalabs_instance", state, config)
+	requireError(t, diags, `Instance failed to restart`)
+	if calls := server.Calls(fakelambda.OperationRestartInstance); calls != 1 {
+		t.Errorf("expected the instance to be restarted once, got %d calls", calls)
+	}
+}
+
+func TestInstanceResourceImportRestartTriggers(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	created := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+
+	// Adding restart_triggers to the configuration of an imported instance does not restart it
+	state, diags := p.importState("lambdalabs_instance", testString(t, created, "id"))
+	requireNoErrors(t, "Import", diags)
+	config := testInstanceResourceConfig(map[string]interface{}{
+		"restart_triggers": map[string]string{"driver": "535"},
+	})
+	state, diags = p.apply("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Update", diags)
+	if calls := server.Calls(fakelambda.OperationRestartInstance); calls != 0 {
+		t.Errorf("expected no restart after import, got %d calls", calls)
+	}
+	p.requireEmptyPlan("lambdalabs_instance", state, config)
+
+	// Changing them afterwards does
+	config["restart_triggers"] = map[string]string{"driver": "545"}
+	_, diags = p.apply("lambdalabs_instance", state, config)
+	requireNoErrors(t, "Update", diags)
+	if calls := server.Calls(fakelambda.OperationRestartInstance); calls != 1 {
+		t.Errorf("expected the instance to be restarted once, got %d calls", calls)
+	}
+}
+
+func testAccInstanceResourceConfig(attributes string) string {
+	return testAccProviderConfig + fmt.Sprintf(`
+resource "lambdalabs_instance" "test" {
+  ssh_key_names = ["acceptance-test"]
+  poll_interval = "10ms"
+%s
+}
+`, attributes)
+}
+
+// testInstanceResourceConfig returns the configuration of an instance of gpu_1x_a10 in us-east-1
+// named acceptance-test, with the acceptance-test SSH key, overridden by attributes.
+func testInstanceResourceConfig(attributes map[string]interface{}) map[string]interface{} {
+	config := map[string]interface{}{
+		"name":          "acceptance-test",
+		"instance_type": "gpu_1x_a10",
+		"region":        "us-east-1",
+		"ssh_key_names": []string{"acceptance-test"},
+		"poll_interval": "10ms",
+	}
+	for name, value := range attributes {
+		config[name] = value
+	}
+	return config
+}
+
+// testCreateInstance creates an instance with config, failing the test on errors.
+func testCreateInstance(t *testing.T, p *testProvider, config map[string]interface{}) tftypes.Value {
+	t.Helper()
+	state, diags := p.apply("lambdalabs_instance", tftypes.NewValue(p.resourceType("lambdalabs_instance"), nil), config)
+	requireNoErrors(t, "Create", diags)
+	return state
+}
+
+// testAccCheckInstanceStatus checks the status of the instance in the fake API.
+func testAccCheckInstanceStatus(server *fakelambda.Server, status lambdalabs.InstanceStatus) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["lambdalabs_instance.test"]
+		if !ok {
+			return fmt.Errorf("lambdalabs_instance.test not found in state")
+		}
+		instance, ok := server.Instance(rs.Primary.ID)
+		if !ok {
+			return fmt.Errorf("instance %s not found in the API", rs.Primary.ID)
+		}
+		if instance.Status != status {
+			return fmt.Errorf("expected instance %s to be %s, got %s", rs.Primary.ID, status, instance.Status)
+		}
+		return nil
+	}
+}
+
+// testAccTerminateInstances terminates every instance out of band.
+func testAccTerminateInstances(server *fakelambda.Server) {
+	for _, instance := range server.Instances() {
+		server.SetInstanceStatus(instance.Id, lambdalabs.InstanceStatusTerminated)
+	}
+}
+
+func TestAccInstanceResource(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	config := testAccInstanceResourceConfig(`
+  name          = "acceptance-test"
+  instance_type = "gpu_1x_a10"
+  region        = "us-east-1"
+  restart_triggers = {
+    image = "v1"
+  }
+`)
+	restartedConfig := testAccInstanceResourceConfig(`
+  name          = "acceptance-test"
+  instance_type = "gpu_1x_a10"
+  region        = "us-east-1"
+  restart_triggers = {
+    image = "v2"
+  }
+`)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			for _, instance := range server.Instances() {
+				if instance.Status != lambdalabs.InstanceStatusTerminated {
+					return fmt.Errorf("expected instance %s to be terminated, got %s", instance.Id, instance.Status)
+				}
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			// Create and Read testing
+			{
+				Config: config,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("id"), knownvalue.NotNull()),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("ids"), knownvalue.SetSizeExact(1)),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("quantity"), knownvalue.Int64Exact(1)),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("status"), knownvalue.StringExact("active")),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("ip"), knownvalue.NotNull()),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("hostname"), knownvalue.NotNull()),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("jupyter_url"), knownvalue.NotNull()),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("restart_triggers"), knownvalue.MapExact(map[string]knownvalue.Check{
+						"image": knownvalue.StringExact("v1"),
+					})),
+				},
+				Check: testAccCheckInstanceStatus(server, lambdalabs.InstanceStatusActive),
+			},
+			// Refreshing an unchanged instance plans nothing
+			{
+				Config:   config,
+				PlanOnly: true,
+			},
+			// ImportState testing, by ID and by name. The API does not know the restart triggers
+			{
+				ResourceName:            "lambdalabs_instance.test",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"poll_interval", "restart_triggers"},
+			},
+			{
+				ResourceName:            "lambdalabs_instance.test",
+				ImportState:             true,
+				ImportStateId:           "name:acceptance-test",
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"poll_interval", "restart_triggers"},
+			},
+			// Changing restart_triggers restarts the instance in place
+			{
+				Config: restartedConfig,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("lambdalabs_instance.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("restart_triggers").AtMapKey("image"), knownvalue.StringExact("v2")),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("status"), knownvalue.StringExact("active")),
+				},
+				Check: func(*terraform.State) error {
+					if calls := server.Calls(fakelambda.OperationRestartInstance); calls != 1 {
+						return fmt.Errorf("expected 1 restart, got %d", calls)
+					}
+					return nil
+				},
+			},
+			// An instance terminated out of band drops out of state, and is planned to be launched again
+			{
+				PreConfig:    func() { testAccTerminateInstances(server) },
+				RefreshState: true,
+				Check: func(s *terraform.State) error {
+					if _, ok := s.RootModule().Resources["lambdalabs_instance.test"]; ok {
+						return fmt.Errorf("expected the terminated instance to be removed from state")
+					}
+					return nil
+				},
+				RefreshPlanChecks: resource.RefreshPlanChecks{
+					PostRefresh: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("lambdalabs_instance.test", plancheck.ResourceActionCreate),
+					},
+				},
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: restartedConfig,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_instance.test", "status", "active"),
+					testAccCheckInstanceStatus(server, lambdalabs.InstanceStatusActive),
+				),
+			},
+			// Delete testing automatically occurs in TestCase
+		},
+	})
+}
+
+func TestAccInstanceResourcePartialLoss(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	config := testAccInstanceResourceConfig(`
+  instance_type = "gpu_1x_a10"
+  region        = "us-east-1"
+  quantity      = 2
+`)
+	var lost string
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("ids"), knownvalue.SetSizeExact(2)),
+				},
+			},
+			// An instance terminated out of band is launched again in place, and quantity stays
+			// as configured
+			{
+				PreConfig: func() {
+					lost = server.Instances()[0].Id
+					server.SetInstanceStatus(lost, lambdalabs.InstanceStatusTerminated)
+				},
+				Config: config,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("lambdalabs_instance.test", plancheck.ResourceActionUpdate),
+						plancheck.ExpectUnknownValue("lambdalabs_instance.test", tfjsonpath.New("ids")),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("ids"), knownvalue.SetSizeExact(2)),
+					statecheck.ExpectKnownValue("lambdalabs_instance.test", tfjsonpath.New("quantity"), knownvalue.Int64Exact(2)),
+				},
+				Check: func(s *terraform.State) error {
+					attributes := s.RootModule().Resources["lambdalabs_instance.test"].Primary.Attributes
+					if attributes["ids.0"] == lost || attributes["ids.1"] == lost {
+						return fmt.Errorf("expected the terminated instance %s to be replaced, got %s and %s", lost, attributes["ids.0"], attributes["ids.1"])
+					}
+					if calls := server.Calls(fakelambda.OperationLaunchInstance); calls != 3 {
+						return fmt.Errorf("expected one more launch, got %d in total", calls)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestAccInstanceResourceCapacity(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The first candidate with capacity is launched
+			{
+				Config: testAccInstanceResourceConfig(`
+  candidates = [
+    { instance_type = "gpu_8x_a100_80gb_sxm4", region = "us-east-1" },
+    { instance_type = "gpu_1x_a100", region = "us-west-1" },
+  ]
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_instance.test", "instance_type", "gpu_1x_a100"),
+					resource.TestCheckResourceAttr("lambdalabs_instance.test", "region", "us-west-1"),
+				),
+			},
+			// Without capacity, the launch waits until capacity appears
+			{
+				PreConfig: func() {
+					server.SetCapacity("gpu_1x_a10", "us-east-1", 0)
+					calls := server.Calls(fakelambda.OperationInstanceTypes)
+					go func() {
+						for server.Calls(fakelambda.OperationInstanceTypes) < calls+5 {
+							time.Sleep(10 * time.Millisecond)
+						}
+						server.SetCapacity("gpu_1x_a10", "us-east-1", 1)
+					}()
+				},
+				Config: testAccInstanceResourceConfig(`
+  instance_type    = "gpu_1x_a10"
+  region           = "us-east-1"
+  capacity_timeout = "1m"
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_instance.test", "status", "active"),
+					resource.TestCheckResourceAttr("lambdalabs_instance.test", "region", "us-east-1"),
+				),
+			},
+			// Capacity taken between listing and launching sends the launch back to waiting
+			{
+				PreConfig: func() {
+					server.InjectError(fakelambda.OperationLaunchInstance, lambdalabs.ErrorCodeInsufficientCapacity, 1)
+				},
+				Config: testAccInstanceResourceConfig(`
+  instance_type    = "gpu_1x_a10"
+  region           = "us-west-1"
+  capacity_timeout = "1m"
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_instance.test", "status", "active"),
+					resource.TestCheckResourceAttr("lambdalabs_instance.test", "region", "us-west-1"),
+				),
+			},
+			// Giving up on capacity fails the apply
+			{
+				PreConfig: func() { server.SetCapacity("gpu_1x_a100", "us-west-1", 0) },
+				Config: testAccInstanceResourceConfig(`
+  instance_type    = "gpu_1x_a100"
+  region           = "us-west-1"
+  capacity_timeout = "100ms"
+`),
+				ExpectError: regexp.MustCompile(`Instance type unavailable`),
+			},
+		},
+	})
+}
+
+func TestAccInstanceResourceErrors(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	server.AddFileSystem("datasets", "us-west-1")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccInstanceResourceConfig(`
+  instance_type = "gpu_1x_a10"
+  region        = "us-east-1"
+  candidates    = [{ instance_type = "gpu_1x_a10", region = "us-west-1" }]
+`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`Conflicting instance_type and candidates`),
+			},
+			{
+				Config: testAccInstanceResourceConfig(`
+  instance_type = "gpu_1x_a1O"
+  region        = "us-east-1"
+`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`Unknown instance type`),
+			},
+			{
+				Config: testAccInstanceResourceConfig(`
+  instance_type    = "gpu_1x_a10"
+  region           = "us-east-1"
+  filesystem_names = ["datasets"]
+`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`Filesystem in wrong region`),
+			},
+			{
+				PreConfig: func() {
+					server.InjectError(fakelambda.OperationLaunchInstance, lambdalabs.ErrorCodeQuotaExceeded, 1)
+				},
+				Config: testAccInstanceResourceConfig(`
+  instance_type = "gpu_1x_a10"
+  region        = "us-east-1"
+`),
+				ExpectError: regexp.MustCompile(`global/quota-exceeded`),
+			},
+		},
+	})
+}
@@ -22,7 +22,7 @@ func NewInstancesDataSource() datasource.DataSource {
 
 // instancesDataSource is the data source implementation.
 type instancesDataSource struct {
-	client *lambdalabs.ClientWithResponses
+	instances lambdalabs.InstancesService
 }
 
 // Metadata returns the data source type name.
@@ -135,23 +135,23 @@ func (d *instancesDataSource) Schema(_ context.Context, _ datasource.SchemaReque
 	}
 }
 
-// Configure adds the provider configured client to the data source.
+// Configure adds the provider configured services to the data source.
 func (d *instancesDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
 	if req.ProviderData == nil {
 		return
 	}
 
-	client, ok := req.ProviderData.(*lambdalabs.ClientWithResponses)
+	services, ok := req.ProviderData.(*lambdalabs.Services)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *lambdalabs.Client, got %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *lambdalabs.Services, got %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.instances = services.Instances
 }
 
 // Read refreshes the Terraform state with the latest data.
@@ -162,50 +162,14 @@ func (d *instancesDataSource) Read(ctx context.Context, req datasource.ReadReque
 		return
 	}
 
-	r, err := d.client.ListInstancesWithResponse(ctx)
+	instances, err := d.instances.List(ctx)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs Instances. Lambda Labs Client Error",
-			err.Error(),
-		)
-		return
-	}
-	if r.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs Instances",
-			string(r.Body),
-		)
+		resp.Diagnostics.Append(apiErrorDiagnostics("Unable to Read Lambda Labs Instances", err, nil)...)
 		return
 	}
 
-	for _, instance := range r.JSON200.Data {
-		instanceState := InstanceDataSourceModel{
-			ID:       types.StringValue(instance.Id),
-			Hostname: types.StringPointerValue(instance.Hostname),
-			Ip:       types.StringPointerValue(instance.Ip),
-			Name:     types.StringPointerValue(instance.Name),
-			Region: &RegionModel{
-				Name:        types.StringValue(instance.Region.Name),
-				Description: types.StringValue(instance.Region.Description),
-			},
-			FileSystemNames: makeTfStringList(instance.FileSystemNames),
-			InstanceType: &InstanceTypeModel{
-				Description:       types.StringValue(instance.InstanceType.Description),
-				Name:              types.StringValue(instance.InstanceType.Name),
-				PriceCentsPerHour: types.Int64Value(int64(instance.InstanceType.PriceCentsPerHour)),
-				Specs: InstanceSpecsModel{
-					MemoryGib:  types.Int64Value(int64(instance.InstanceType.Specs.MemoryGib)),
-					StorageGib: types.Int64Value(int64(instance.InstanceType.Specs.StorageGib)),
-					Vcpus:      types.Int64Value(int64(instance.InstanceType.Specs.Vcpus)),
-				},
-			},
-			JupyterToken: types.StringPointerValue(instance.JupyterToken),
-			JupyterUrl:   types.StringPointerValue(instance.JupyterUrl),
-			SshKeyNames:  makeTfStringList(instance.SshKeyNames),
-			Status:       types.StringValue(string(instance.Status)),
-		}
-
-		state.Instances = append(state.Instances, instanceState)
+	for _, instance := range instances {
+		state.Instances = append(state.Instances, newInstanceDataSourceModel(instance))
 	}
 
 	// Set state
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"terraform-provider-lambdalabs/internal/fakelambda"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccInstancesDataSource(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig + `data "lambdalabs_instances" "test" {}`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.lambdalabs_instances.test", "instances.#", "0"),
+				),
+			},
+			{
+				Config: testAccInstanceResourceConfig(`
+  name          = "acceptance-test"
+  instance_type = "gpu_1x_a10"
+  region        = "us-east-1"
+`) + `
+data "lambdalabs_instances" "test" {
+  depends_on = [lambdalabs_instance.test]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.lambdalabs_instances.test", "instances.#", "1"),
+					resource.TestCheckResourceAttrPair("data.lambdalabs_instances.test", "instances.0.id", "lambdalabs_instance.test", "id"),
+					resource.TestCheckResourceAttr("data.lambdalabs_instances.test", "instances.0.name", "acceptance-test"),
+					resource.TestCheckResourceAttr("data.lambdalabs_instances.test", "instances.0.region.name", "us-east-1"),
+				),
+			},
+		},
+	})
+}
+
+func TestInstancesDataSourceRead(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+
+	state, diags := p.readDataSource("lambdalabs_instances", nil)
+	requireNoErrors(t, "Read", diags)
+	var instances []tftypes.Value
+	if err := testAttribute(t, state, "instances").As(&instances); err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 0 {
+		t.Fatalf("expected no instances, got %d", len(instances))
+	}
+
+	instance := testCreateInstance(t, p, testInstanceResourceConfig(nil))
+	state, diags = p.readDataSource("lambdalabs_instances", nil)
+	requireNoErrors(t, "Read", diags)
+	if err := testAttribute(t, state, "instances").As(&instances); err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 1 {
+		t.Fatalf("expected 1 instance, got %d", len(instances))
+	}
+	if id := testString(t, instances[0], "id"); id != testString(t, instance, "id") {
+		t.Errorf("expected instance %s, got %s", testString(t, instance, "id"), id)
+	}
+	if name := testString(t, instances[0], "name"); name != "acceptance-test" {
+		t.Errorf("expected name acceptance-test, got %q", name)
+	}
+	if region := testString(t, instances[0], "region", "name"); region != "us-east-1" {
+		t.Errorf("expected region us-east-1, got %q", region)
+	}
+}
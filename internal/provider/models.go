@@ -1,7 +1,9 @@
 package provider
 
 import (
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
 )
 
 // RegionModel Region where an instance (or filesystem) is located.
@@ -15,9 +17,31 @@ type RegionModel struct {
 
 // SshKeyResourceModel describes the resource data model.
 type SshKeyResourceModel struct {
-	Id        types.String `tfsdk:"id"`
-	Name      types.String `tfsdk:"name"`
-	PublicKey types.String `tfsdk:"public_key"`
+	Id                types.String `tfsdk:"id"`
+	Name              types.String `tfsdk:"name"`
+	PublicKey         types.String `tfsdk:"public_key"`
+	PrivateKey        types.String `tfsdk:"private_key"`
+	PrivateKeyFile    types.String `tfsdk:"private_key_file"`
+	FingerprintSHA256 types.String `tfsdk:"fingerprint_sha256"`
+	FingerprintMD5    types.String `tfsdk:"fingerprint_md5"`
+	KeyType           types.String `tfsdk:"key_type"`
+	// Timeouts Delete timeout
+	Timeouts timeouts.Value `tfsdk:"timeouts"`
+}
+
+// SshKeySetResourceModel describes the lambdalabs_ssh_key_set data model.
+type SshKeySetResourceModel struct {
+	ID types.String `tfsdk:"id"`
+	// Keys Public keys by SSH key name
+	Keys types.Map `tfsdk:"keys"`
+	// Exclusive Whether keys of the account that are not declared are deleted
+	Exclusive types.Bool `tfsdk:"exclusive"`
+	// KeyIDs SSH key IDs by name
+	KeyIDs types.Map `tfsdk:"key_ids"`
+	// UndeclaredKeys Names of the keys of the account that are not declared
+	UndeclaredKeys types.Set `tfsdk:"undeclared_keys"`
+	// Timeouts Delete timeout
+	Timeouts timeouts.Value `tfsdk:"timeouts"`
 }
 
 // filesystemModel maps filesystem schema data.
@@ -33,9 +57,13 @@ type filesystemModel struct {
 
 // sshkeyDataSourceModel maps the data source schema data.
 type sshkeyDataSourceModel struct {
-	ID        types.String `tfsdk:"id"`
-	Name      types.String `tfsdk:"name"`
-	PublicKey types.String `tfsdk:"public_key"`
+	ID                types.String `tfsdk:"id"`
+	Name              types.String `tfsdk:"name"`
+	Fingerprint       types.String `tfsdk:"fingerprint"`
+	PublicKey         types.String `tfsdk:"public_key"`
+	FingerprintSHA256 types.String `tfsdk:"fingerprint_sha256"`
+	FingerprintMD5    types.String `tfsdk:"fingerprint_md5"`
+	KeyType           types.String `tfsdk:"key_type"`
 }
 
 // InstanceSpecsModel Hardware configuration of an instance type.
@@ -101,17 +129,57 @@ type InstanceDataSourceModel struct {
 	Status types.String `tfsdk:"status"`
 }
 
+// newInstanceDataSourceModel maps an instance returned by the API to the data source model.
+func newInstanceDataSourceModel(instance lambdalabs.Instance) InstanceDataSourceModel {
+	model := InstanceDataSourceModel{
+		ID:              types.StringValue(instance.Id),
+		Hostname:        types.StringPointerValue(instance.Hostname),
+		Ip:              types.StringPointerValue(instance.Ip),
+		Name:            types.StringPointerValue(instance.Name),
+		FileSystemNames: makeTfStringList(instance.FileSystemNames),
+		JupyterToken:    types.StringPointerValue(instance.JupyterToken),
+		JupyterUrl:      types.StringPointerValue(instance.JupyterUrl),
+		SshKeyNames:     makeTfStringList(instance.SshKeyNames),
+		Status:          types.StringValue(string(instance.Status)),
+	}
+	if instance.Region != nil {
+		model.Region = &RegionModel{
+			Name:        types.StringValue(instance.Region.Name),
+			Description: types.StringValue(instance.Region.Description),
+		}
+	}
+	if instance.InstanceType != nil {
+		model.InstanceType = &InstanceTypeModel{
+			Description:       types.StringValue(instance.InstanceType.Description),
+			Name:              types.StringValue(instance.InstanceType.Name),
+			PriceCentsPerHour: types.Int64Value(int64(instance.InstanceType.PriceCentsPerHour)),
+			Specs: InstanceSpecsModel{
+				MemoryGib:  types.Int64Value(int64(instance.InstanceType.Specs.MemoryGib)),
+				StorageGib: types.Int64Value(int64(instance.InstanceType.Specs.StorageGib)),
+				Vcpus:      types.Int64Value(int64(instance.InstanceType.Specs.Vcpus)),
+			},
+		}
+	}
+	return model
+}
+
 // InstancesDataSourceModel maps the data source schema data.
 type InstancesDataSourceModel struct {
 	Instances []InstanceDataSourceModel `tfsdk:"instances"`
 }
 
+// instanceCandidateModel is an instance type and region pair that may be launched.
+type instanceCandidateModel struct {
+	InstanceType types.String `tfsdk:"instance_type"`
+	Region       types.String `tfsdk:"region"`
+}
+
 // InstanceResourceModel defines parameters for provisioning an instance.
 type InstanceResourceModel struct {
 	// ID Unique identifier (ID) of an instance (only valid when quantity is 1)
 	ID types.String `tfsdk:"id"`
 	// IDs Unique identifiers (IDs) of instances
-	//IDs types.Set `tfsdk:"ids"`
+	IDs types.Set `tfsdk:"ids"`
 	// FileSystemNames Names of the file systems, if any, attached to the instance
 	FileSystemNames []types.String `tfsdk:"filesystem_names"`
 	// InstanceTypeName Name of an instance type
@@ -119,9 +187,29 @@ type InstanceResourceModel struct {
 	// Name User-provided name of the instance
 	Name types.String `tfsdk:"name"`
 	// Quantity Number of instances to provision
-	//Quantity types.Int64 `tfsdk:"quantity"`
+	Quantity types.Int64 `tfsdk:"quantity"`
 	// RegionName Name of the region where the instance is located
 	RegionName types.String `tfsdk:"region"`
+	// Candidates Ordered instance type and region pairs to fall back on
+	Candidates []instanceCandidateModel `tfsdk:"candidates"`
 	// SshKeyNames Names of the SSH keys allowed to access the instance. Currently, exactly one SSH key must be specified.
 	SshKeyNames []types.String `tfsdk:"ssh_key_names"`
+	// Hostname assigned to this instance, which resolves to the instance's IP.
+	Hostname types.String `tfsdk:"hostname"`
+	// Ip IPv4 address of the instance
+	Ip types.String `tfsdk:"ip"`
+	// JupyterToken Secret token used to log into the jupyter lab server hosted on the instance.
+	JupyterToken types.String `tfsdk:"jupyter_token"`
+	// JupyterUrl URL that opens a jupyter lab notebook on the instance.
+	JupyterUrl types.String `tfsdk:"jupyter_url"`
+	// Status The current status of the instance
+	Status types.String `tfsdk:"status"`
+	// CapacityTimeout How long to wait for capacity before giving up
+	CapacityTimeout types.String `tfsdk:"capacity_timeout"`
+	// PollInterval How often to poll the API while waiting
+	PollInterval types.String `tfsdk:"poll_interval"`
+	// RestartTriggers Arbitrary values that restart the instances in place when changed
+	RestartTriggers types.Map `tfsdk:"restart_triggers"`
+	// Timeouts Create, update and delete timeouts
+	Timeouts timeouts.Value `tfsdk:"timeouts"`
 }
@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// TestModelsMatchSchemas checks that every model has exactly the attributes of the schema it is
+// read and written with, which the framework only reports when the model is used.
+func TestModelsMatchSchemas(t *testing.T) {
+	ctx := context.Background()
+
+	dataSourceType := func(d datasource.DataSource) tftypes.Type {
+		resp := &datasource.SchemaResponse{}
+		d.Schema(ctx, datasource.SchemaRequest{}, resp)
+		return resp.Schema.Type().TerraformType(ctx)
+	}
+	resourceType := func(r resource.Resource) tftypes.Type {
+		resp := &resource.SchemaResponse{}
+		r.Schema(ctx, resource.SchemaRequest{}, resp)
+		return resp.Schema.Type().TerraformType(ctx)
+	}
+
+	tests := map[string]struct {
+		schemaType tftypes.Type
+		model      interface{}
+	}{
+		"lambdalabs_instance data source":    {dataSourceType(NewInstanceDataSource()), InstanceDataSourceModel{}},
+		"lambdalabs_instances data source":   {dataSourceType(NewInstancesDataSource()), InstancesDataSourceModel{}},
+		"lambdalabs_filesystems data source": {dataSourceType(NewFilesystemDataSource()), filesystemsDataSourceModel{}},
+		"lambdalabs_ssh_key data source":     {dataSourceType(NewSSHKeyDataSource()), sshkeyDataSourceModel{}},
+		"lambdalabs_ssh_keys data source":    {dataSourceType(NewSSHKeysDataSource()), sshkeysDataSourceModel{}},
+		"lambdalabs_instance resource":       {resourceType(NewInstanceResource()), InstanceResourceModel{}},
+		"lambdalabs_ssh_key resource":        {resourceType(NewSSHKeyResource()), SshKeyResourceModel{}},
+		"lambdalabs_ssh_key_set resource":    {resourceType(NewSSHKeySetResource()), SshKeySetResourceModel{}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checkModelMatchesType(t, "", reflect.TypeOf(test.model), test.schemaType)
+		})
+	}
+}
+
+// checkModelMatchesType compares the tfsdk tags of a model struct with the attributes of
+// an object type, and recurses into nested models.
+func checkModelMatchesType(t *testing.T, path string, modelType reflect.Type, schemaType tftypes.Type) {
+	t.Helper()
+	for modelType.Kind() == reflect.Pointer || modelType.Kind() == reflect.Slice {
+		modelType = modelType.Elem()
+	}
+	if modelType.Kind() != reflect.Struct || modelType.Implements(reflect.TypeOf((*attr.Value)(nil)).Elem()) {
+		return
+	}
+	switch collection := schemaType.(type) {
+	case tftypes.List:
+		schemaType = collection.ElementType
+	case tftypes.Set:
+		schemaType = collection.ElementType
+	}
+	object, ok := schemaType.(tftypes.Object)
+	if !ok {
+		t.Errorf("model %s of %q is a struct, but the schema type is %s", modelType, path, schemaType)
+		return
+	}
+
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		if tag := field.Tag.Get("tfsdk"); tag != "" && tag != "-" {
+			fields[tag] = field.Type
+		}
+	}
+	names := make([]string, 0, len(fields)+len(object.AttributeTypes))
+	for name := range fields {
+		names = append(names, name)
+	}
+	for name := range object.AttributeTypes {
+		if _, ok := fields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fieldType, inModel := fields[name]
+		attributeType, inSchema := object.AttributeTypes[name]
+		switch {
+		case !inSchema:
+			t.Errorf("model %s has field %q, which is not in the schema", modelType, path+name)
+		case !inModel:
+			t.Errorf("model %s has no field for attribute %q", modelType, path+name)
+		default:
+			checkModelMatchesType(t, path+name+".", fieldType, attributeType)
+		}
+	}
+}
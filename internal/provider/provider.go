@@ -7,10 +7,14 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"math"
+	"net/http"
 	"os"
 	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"time"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
@@ -18,10 +22,26 @@ var (
 	_ provider.Provider = &lambdalabsProvider{}
 )
 
+// defaultHTTPTimeout is the time limit of a single API request.
+const defaultHTTPTimeout = time.Minute
+
 // lambdalabsProviderModel maps provider schema data to a Go type.
 type lambdalabsProviderModel struct {
-	Host   types.String `tfsdk:"host"`
-	ApiKey types.String `tfsdk:"api_key"`
+	Host                 types.String        `tfsdk:"host"`
+	ApiKey               types.String        `tfsdk:"api_key"`
+	MaxRequestsPerSecond types.Float64       `tfsdk:"max_requests_per_second"`
+	CACertFile           types.String        `tfsdk:"ca_cert_file"`
+	CACertPEM            types.String        `tfsdk:"ca_cert_pem"`
+	ProxyURL             types.String        `tfsdk:"proxy_url"`
+	InsecureSkipVerify   types.Bool          `tfsdk:"insecure_skip_verify"`
+	HTTPTimeout          types.String        `tfsdk:"http_timeout"`
+	Retry                *providerRetryModel `tfsdk:"retry"`
+}
+
+// providerRetryModel maps the retry block of the provider configuration.
+type providerRetryModel struct {
+	MaxAttempts types.Int64  `tfsdk:"max_attempts"`
+	MaxBackoff  types.String `tfsdk:"max_backoff"`
 }
 
 // lambdalabsProvider is the provider implementation.
@@ -30,6 +50,10 @@ type lambdalabsProvider struct {
 	// provider is built and ran locally, and "test" when running acceptance
 	// testing.
 	version string
+
+	// httpClient replaces the HTTP client built from the configuration when set,
+	// e.g. to replay recorded API calls in tests.
+	httpClient lambdalabs.HttpRequestDoer
 }
 
 // Metadata returns the provider type name.
@@ -52,6 +76,63 @@ func (p *lambdalabsProvider) Schema(_ context.Context, _ provider.SchemaRequest,
 				Sensitive:           true,
 				MarkdownDescription: "Lambda Labs API key",
 			},
+			"max_requests_per_second": schema.Float64Attribute{
+				MarkdownDescription: "Maximum average number of API requests per second, shared by all resources and data sources " +
+					"of this provider. Set to `0` to disable rate limiting. Defaults to `1`, the documented rate limit of the API.",
+				Optional: true,
+				Validators: []validator.Float64{
+					Float64AtLeast{min: 0},
+				},
+			},
+			"ca_cert_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a PEM file of certificate authorities to trust in addition to the system ones, " +
+					"e.g. the CA of a TLS-intercepting proxy. Can also be set with the `LAMBDALABS_CA_CERT_FILE` environment variable.",
+				Optional: true,
+			},
+			"ca_cert_pem": schema.StringAttribute{
+				MarkdownDescription: "PEM encoded certificate authorities to trust in addition to the system ones.",
+				Optional:            true,
+			},
+			"proxy_url": schema.StringAttribute{
+				MarkdownDescription: "URL of the proxy to reach the API through, such as `http://proxy:3128`. " +
+					"Defaults to the `HTTPS_PROXY` and `NO_PROXY` environment variables.",
+				Optional: true,
+			},
+			"insecure_skip_verify": schema.BoolAttribute{
+				MarkdownDescription: "Skip verification of the API server certificate. Only meant for local stand-ins of the API. Defaults to `false`.",
+				Optional:            true,
+			},
+			"http_timeout": schema.StringAttribute{
+				MarkdownDescription: "Time limit of a single API request, as a duration string such as `30s` or `2m`. " +
+					"Each retry has its own time limit. Defaults to `1m`.",
+				Optional: true,
+				Validators: []validator.String{
+					DurationValidator{},
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"retry": schema.SingleNestedBlock{
+				MarkdownDescription: "Retrying of API requests that failed with a network error, `429 Too Many Requests` or a `5xx` status code. " +
+					"Requests that launch instances or add SSH keys are only retried on `429`, since they are not safe to send twice.",
+				Attributes: map[string]schema.Attribute{
+					"max_attempts": schema.Int64Attribute{
+						MarkdownDescription: "Number of attempts per request, including the first one. Set to `1` to disable retries. Defaults to `5`.",
+						Optional:            true,
+						Validators: []validator.Int64{
+							Int64AtLeast{min: 1},
+						},
+					},
+					"max_backoff": schema.StringAttribute{
+						MarkdownDescription: "Upper bound of the wait between attempts, as a duration string such as `30s` or `2m`. " +
+							"Waits requested by the API through `Retry-After` are capped to it as well. Defaults to `30s`.",
+						Optional: true,
+						Validators: []validator.String{
+							DurationValidator{},
+						},
+					},
+				},
+			},
 		},
 	}
 }
@@ -120,14 +201,74 @@ func (p *lambdalabsProvider) Configure(ctx context.Context, req provider.Configu
 		return
 	}
 
+	httpTimeout, err := parseDuration(config.HTTPTimeout, defaultHTTPTimeout)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("http_timeout"), "Invalid http_timeout", err.Error())
+		return
+	}
+	caCertFile := os.Getenv("LAMBDALABS_CA_CERT_FILE")
+	if !config.CACertFile.IsNull() {
+		caCertFile = config.CACertFile.ValueString()
+	}
+	httpClient := p.httpClient
+	if httpClient == nil {
+		defaultHTTPClient, err := lambdalabs.NewHTTPClient(lambdalabs.HTTPClientOptions{
+			CACertFile:         caCertFile,
+			CACertPEM:          config.CACertPEM.ValueString(),
+			ProxyURL:           config.ProxyURL.ValueString(),
+			InsecureSkipVerify: config.InsecureSkipVerify.ValueBool(),
+			Timeout:            httpTimeout,
+		})
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Invalid Lambda Labs HTTP Client Settings",
+				"The provider cannot create the HTTP client for the Lambda Labs API from the ca_cert_file, ca_cert_pem and proxy_url settings: "+err.Error(),
+			)
+			return
+		}
+		httpClient = defaultHTTPClient
+	}
+
 	ctx = tflog.SetField(ctx, "lambdalabs_host", host)
 	ctx = tflog.SetField(ctx, "lambdalabs_api_key", apiKey)
 	ctx = tflog.MaskFieldValuesWithFieldKeys(ctx, "lambdalabs_api_key")
 
 	tflog.Debug(ctx, "Creating Lambda Labs client")
 
-	// Create a new LambdaLabs client using the configuration values
-	lambdaclient, err := lambdalabs.NewAuthenticatedClient(host, apiKey)
+	retryOptions := lambdalabs.RetryOptions{}
+	if config.Retry != nil {
+		retryOptions.MaxAttempts = int(config.Retry.MaxAttempts.ValueInt64())
+		maxBackoff, err := parseDuration(config.Retry.MaxBackoff, lambdalabs.DefaultRetryMaxBackoff)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("retry").AtName("max_backoff"), "Invalid max_backoff", err.Error())
+			return
+		}
+		retryOptions.MaxBackoff = maxBackoff
+	}
+
+	requestsPerSecond := lambdalabs.DefaultRequestsPerSecond
+	if !config.MaxRequestsPerSecond.IsNull() && !config.MaxRequestsPerSecond.IsUnknown() {
+		requestsPerSecond = config.MaxRequestsPerSecond.ValueFloat64()
+	}
+
+	// Create a new LambdaLabs client using the configuration values. When logging is on, every
+	// attempt is logged. The rate limiter sits below the retries so that retried requests are
+	// rate limited as well, and the cache sits on top so that concurrent refreshes share list
+	// responses without being rate limited.
+	options := []lambdalabs.ClientOption{lambdalabs.WithHTTPClient(httpClient)}
+	if lambdalabs.LogLevel() != "" {
+		options = append(options, lambdalabs.WithLogging())
+	}
+	options = append(options,
+		lambdalabs.WithRateLimit(lambdalabs.RateLimitOptions{
+			RequestsPerSecond: requestsPerSecond,
+			Burst:             int(math.Ceil(requestsPerSecond)),
+			OnWait:            logRateLimitWait,
+		}),
+		lambdalabs.WithRetry(retryOptions),
+		lambdalabs.WithCache(lambdalabs.DefaultCacheTTL),
+	)
+	lambdaclient, err := lambdalabs.NewAuthenticatedClient(host, apiKey, options...)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Unable to Create Lambda Labs API Client",
@@ -138,14 +279,23 @@ func (p *lambdalabsProvider) Configure(ctx context.Context, req provider.Configu
 		return
 	}
 
-	// Make the Lambda Labs client available during DataSource and Resource
+	// Make the Lambda Labs services available during DataSource and Resource
 	// type Configure methods.
-
-	resp.DataSourceData = lambdaclient
-	resp.ResourceData = lambdaclient
+	services := lambdalabs.NewServices(lambdaclient)
+	resp.DataSourceData = services
+	resp.ResourceData = services
 	tflog.Info(ctx, "Configured Lambda Labs client", map[string]any{"success": true})
 }
 
+// logRateLimitWait logs requests that were held back by the client-side rate limiter.
+func logRateLimitWait(ctx context.Context, req *http.Request, wait time.Duration) {
+	tflog.Debug(ctx, "Waited for Lambda Labs API rate limit", map[string]interface{}{
+		"method": req.Method,
+		"path":   req.URL.Path,
+		"wait":   wait.String(),
+	})
+}
+
 // DataSources defines the data sources implemented in the provider.
 func (p *lambdalabsProvider) DataSources(_ context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
@@ -171,5 +321,6 @@ func (p *lambdalabsProvider) Resources(_ context.Context) []func() resource.Reso
 	return []func() resource.Resource{
 		NewInstanceResource,
 		NewSSHKeyResource,
+		NewSSHKeySetResource,
 	}
 }
@@ -4,18 +4,77 @@
 package provider
 
 import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"terraform-provider-lambdalabs/internal/contracttest"
+	"terraform-provider-lambdalabs/internal/fakelambda"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 )
 
 // testAccProtoV6ProviderFactories are used to instantiate a provider during
 // acceptance testing. The factory function will be invoked for every Terraform
 // CLI command executed to create a provider server to which the CLI can
-// reattach.
+// reattach. Every API call is checked against openapi.yaml.
 var testAccProtoV6ProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
-	"scaffolding": providerserver.NewProtocol6WithError(New("test")()),
+	"lambdalabs": func() (tfprotov6.ProviderServer, error) {
+		httpClient, err := testAccContractValidatingDoer(nil)
+		if err != nil {
+			return nil, err
+		}
+		return providerserver.NewProtocol6WithError(&lambdalabsProvider{
+			version:    "test",
+			httpClient: httpClient,
+		})()
+	},
+}
+
+// testAccContractValidatingDoer wraps doer to fail any API call whose request or response does
+// not conform to openapi.yaml, so that neither the provider nor the fake API and recorded
+// responses it is tested against drift from the specification.
+func testAccContractValidatingDoer(doer lambdalabs.HttpRequestDoer) (lambdalabs.HttpRequestDoer, error) {
+	spec, err := os.ReadFile(filepath.Join("..", "..", "openapi.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	return contracttest.NewContractValidatingDoer(spec, doer)
+}
+
+// testAccProviderConfig disables the client-side rate limit, which only slows down tests
+// against the fake API.
+const testAccProviderConfig = `
+provider "lambdalabs" {
+  max_requests_per_second = 0
+}
+`
+
+// testAccPublicKey is a valid public key for SSH keys created by tests.
+const testAccPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGcQYOS6lHnQ5EBcLvAdmuM7UTn0yuupDWR+8Ng0jkTZ acceptance-test"
+
+// testAccOtherPublicKey is a valid public key that differs from testAccPublicKey.
+const testAccOtherPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKVpipEbbw8wLrRfdVWj+sVeX1wfBUsaaqUGQQ35UfQ/ rotated"
+
+// testAccFakeServer starts a fake Lambda Cloud API and points the provider at it through the
+// LAMBDALABS_HOST and LAMBDALABS_API_KEY environment variables, so that acceptance tests run
+// without network access or credentials.
+func testAccFakeServer(t *testing.T) *fakelambda.Server {
+	t.Helper()
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	t.Setenv("LAMBDALABS_HOST", server.URL)
+	t.Setenv("LAMBDALABS_API_KEY", fakelambda.APIKey)
+	return server
 }
 
 func testAccPreCheck(t *testing.T) {
@@ -23,3 +82,636 @@ func testAccPreCheck(t *testing.T) {
 	// about the appropriate environment variables being set are common to see in a pre-check
 	// function.
 }
+
+// testProvider drives the provider through the plugin protocol the way Terraform does, so that
+// resources and data sources run end to end against the fake API in unit tests, without the
+// Terraform CLI. Attributes missing from configurations are null, and only top-level computed
+// attributes are carried over from the prior state when planning, which is all the schemas of
+// this provider need.
+type testProvider struct {
+	t       *testing.T
+	server  tfprotov6.ProviderServer
+	schemas *tfprotov6.GetProviderSchemaResponse
+}
+
+// newTestProvider configures the provider against the fake API. Every API call is checked
+// against openapi.yaml.
+func newTestProvider(t *testing.T, server *fakelambda.Server) *testProvider {
+	t.Helper()
+	return newTestProviderWithHook(t, server, nil)
+}
+
+// newTestProviderWithHook configures the provider against the fake API like newTestProvider,
+// and calls before with every API request before it is sent, e.g. to change the fake API in
+// the middle of an apply.
+func newTestProviderWithHook(t *testing.T, server *fakelambda.Server, before func(req *http.Request)) *testProvider {
+	t.Helper()
+	var doer lambdalabs.HttpRequestDoer
+	if before != nil {
+		doer = testHookDoer{before: before}
+	}
+	httpClient, err := testAccContractValidatingDoer(doer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestProviderWithHTTPClient(t, httpClient, map[string]interface{}{
+		"host":                    server.URL,
+		"api_key":                 fakelambda.APIKey,
+		"max_requests_per_second": 0,
+	})
+}
+
+// testHookDoer calls before with every request, and then sends it with http.DefaultClient.
+type testHookDoer struct {
+	before func(req *http.Request)
+}
+
+func (d testHookDoer) Do(req *http.Request) (*http.Response, error) {
+	d.before(req)
+	return http.DefaultClient.Do(req)
+}
+
+// newTestProviderWithHTTPClient configures the provider with config, sending API calls with httpClient.
+func newTestProviderWithHTTPClient(t *testing.T, httpClient lambdalabs.HttpRequestDoer, config map[string]interface{}) *testProvider {
+	t.Helper()
+	ctx := context.Background()
+	server := providerserver.NewProtocol6(&lambdalabsProvider{version: "test", httpClient: httpClient})()
+	schemas, err := server.GetProviderSchema(ctx, &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{t: t, server: server, schemas: schemas}
+	p.requireNoErrors("GetProviderSchema", schemas.Diagnostics)
+
+	resp, err := server.ConfigureProvider(ctx, &tfprotov6.ConfigureProviderRequest{
+		Config: p.dynamicValue(testValue(t, schemas.Provider.ValueType(), config)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.requireNoErrors("ConfigureProvider", resp.Diagnostics)
+	return p
+}
+
+// resourceType returns the type of the state of a resource.
+func (p *testProvider) resourceType(typeName string) tftypes.Type {
+	schema, ok := p.schemas.ResourceSchemas[typeName]
+	if !ok {
+		p.t.Fatalf("unknown resource %s", typeName)
+	}
+	return schema.ValueType()
+}
+
+// plan validates config and plans the change of a resource from prior, which is null for
+// resources that do not exist yet. A nil config plans to destroy the resource.
+func (p *testProvider) plan(typeName string, prior tftypes.Value, config map[string]interface{}) (*tfprotov6.PlanResourceChangeResponse, tftypes.Value) {
+	p.t.Helper()
+	ctx := context.Background()
+	schema := p.schemas.ResourceSchemas[typeName]
+	resourceType := p.resourceType(typeName)
+
+	configValue := tftypes.NewValue(resourceType, nil)
+	proposed := configValue
+	if config != nil {
+		configValue = testValue(p.t, resourceType, config)
+		validated, err := p.server.ValidateResourceConfig(ctx, &tfprotov6.ValidateResourceConfigRequest{
+			TypeName: typeName,
+			Config:   p.dynamicValue(configValue),
+		})
+		if err != nil {
+			p.t.Fatal(err)
+		}
+		if testHasErrors(validated.Diagnostics) {
+			return &tfprotov6.PlanResourceChangeResponse{Diagnostics: validated.Diagnostics}, tftypes.NewValue(resourceType, nil)
+		}
+		proposed = proposedNewState(p.t, schema, prior, configValue)
+	}
+
+	resp, err := p.server.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{
+		TypeName:         typeName,
+		PriorState:       p.dynamicValue(prior),
+		ProposedNewState: p.dynamicValue(proposed),
+		Config:           p.dynamicValue(configValue),
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if config != nil && !testHasErrors(resp.Diagnostics) {
+		// Terraform rejects plans that do not follow the configuration
+		plannedState := p.value(resp.PlannedState, resourceType)
+		if err := testPlanValid(schema.Block, prior, configValue, plannedState); err != nil {
+			p.t.Fatalf("%s produced an invalid plan: %s", typeName, err)
+		}
+	}
+	return resp, configValue
+}
+
+// apply plans and applies the change of a resource from prior to config, as terraform apply
+// would, replacing the resource if the plan requires it. It returns the new state, which is
+// null once the resource is destroyed, along with the diagnostics of every step.
+func (p *testProvider) apply(typeName string, prior tftypes.Value, config map[string]interface{}) (tftypes.Value, []*tfprotov6.Diagnostic) {
+	p.t.Helper()
+	ctx := context.Background()
+	resourceType := p.resourceType(typeName)
+
+	planned, configValue := p.plan(typeName, prior, config)
+	if testHasErrors(planned.Diagnostics) {
+		return prior, planned.Diagnostics
+	}
+	diags := planned.Diagnostics
+	if len(planned.RequiresReplace) > 0 && !prior.IsNull() {
+		destroyed, destroyDiags := p.apply(typeName, prior, nil)
+		diags = append(diags, destroyDiags...)
+		if testHasErrors(destroyDiags) {
+			return destroyed, diags
+		}
+		created, createDiags := p.apply(typeName, destroyed, config)
+		return created, append(diags, createDiags...)
+	}
+
+	resp, err := p.server.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{
+		TypeName:       typeName,
+		PriorState:     p.dynamicValue(prior),
+		PlannedState:   planned.PlannedState,
+		Config:         p.dynamicValue(configValue),
+		PlannedPrivate: planned.PlannedPrivate,
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	diags = append(diags, resp.Diagnostics...)
+	if resp.NewState == nil {
+		return prior, diags
+	}
+	newState := p.value(resp.NewState, resourceType)
+	// Terraform rejects unknown values after apply, even when the apply failed
+	if !newState.IsFullyKnown() {
+		p.t.Fatalf("%s left unknown values in the state after apply: %s", typeName, newState)
+	}
+	if !testHasErrors(resp.Diagnostics) {
+		// Terraform rejects results that differ from the known values of the plan
+		plannedState := p.value(planned.PlannedState, resourceType)
+		if err := testConsistentWithPlan(plannedState, newState); err != nil {
+			p.t.Fatalf("%s produced an inconsistent result after apply: %s", typeName, err)
+		}
+	}
+	return newState, diags
+}
+
+// requireEmptyPlan refreshes state and fails the test unless planning config then has no
+// changes, which acceptance tests check after every apply.
+func (p *testProvider) requireEmptyPlan(typeName string, state tftypes.Value, config map[string]interface{}) {
+	p.t.Helper()
+	refreshed, diags := p.read(typeName, state)
+	p.requireNoErrors("Read", diags)
+	planned, _ := p.plan(typeName, refreshed, config)
+	p.requireNoErrors("Plan", planned.Diagnostics)
+	plannedState := p.value(planned.PlannedState, p.resourceType(typeName))
+	if len(planned.RequiresReplace) > 0 || !plannedState.Equal(refreshed) {
+		diffs, _ := refreshed.Diff(plannedState)
+		p.t.Fatalf("expected an empty plan for %s, got changes to %v and replacement for %v", typeName, diffs, planned.RequiresReplace)
+	}
+}
+
+// read refreshes the state of a resource. The state is null if the resource no longer exists.
+func (p *testProvider) read(typeName string, state tftypes.Value) (tftypes.Value, []*tfprotov6.Diagnostic) {
+	p.t.Helper()
+	resp, err := p.server.ReadResource(context.Background(), &tfprotov6.ReadResourceRequest{
+		TypeName:     typeName,
+		CurrentState: p.dynamicValue(state),
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if resp.NewState == nil {
+		return state, resp.Diagnostics
+	}
+	return p.value(resp.NewState, p.resourceType(typeName)), resp.Diagnostics
+}
+
+// importState imports a resource by id and refreshes it, as terraform import would.
+func (p *testProvider) importState(typeName string, id string) (tftypes.Value, []*tfprotov6.Diagnostic) {
+	p.t.Helper()
+	resp, err := p.server.ImportResourceState(context.Background(), &tfprotov6.ImportResourceStateRequest{
+		TypeName: typeName,
+		ID:       id,
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if testHasErrors(resp.Diagnostics) {
+		return tftypes.NewValue(p.resourceType(typeName), nil), resp.Diagnostics
+	}
+	if len(resp.ImportedResources) != 1 {
+		p.t.Fatalf("expected 1 imported resource, got %d", len(resp.ImportedResources))
+	}
+	state, diags := p.read(typeName, p.value(resp.ImportedResources[0].State, p.resourceType(typeName)))
+	return state, append(resp.Diagnostics, diags...)
+}
+
+// readDataSource validates config and reads a data source.
+func (p *testProvider) readDataSource(typeName string, config map[string]interface{}) (tftypes.Value, []*tfprotov6.Diagnostic) {
+	p.t.Helper()
+	ctx := context.Background()
+	schema, ok := p.schemas.DataSourceSchemas[typeName]
+	if !ok {
+		p.t.Fatalf("unknown data source %s", typeName)
+	}
+	configValue := p.dynamicValue(testValue(p.t, schema.ValueType(), config))
+
+	validated, err := p.server.ValidateDataResourceConfig(ctx, &tfprotov6.ValidateDataResourceConfigRequest{
+		TypeName: typeName,
+		Config:   configValue,
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if testHasErrors(validated.Diagnostics) {
+		return tftypes.NewValue(schema.ValueType(), nil), validated.Diagnostics
+	}
+
+	resp, err := p.server.ReadDataSource(ctx, &tfprotov6.ReadDataSourceRequest{
+		TypeName: typeName,
+		Config:   configValue,
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if resp.State == nil {
+		return tftypes.NewValue(schema.ValueType(), nil), resp.Diagnostics
+	}
+	return p.value(resp.State, schema.ValueType()), resp.Diagnostics
+}
+
+func (p *testProvider) dynamicValue(value tftypes.Value) *tfprotov6.DynamicValue {
+	p.t.Helper()
+	dynamicValue, err := tfprotov6.NewDynamicValue(value.Type(), value)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return &dynamicValue
+}
+
+func (p *testProvider) value(dynamicValue *tfprotov6.DynamicValue, valueType tftypes.Type) tftypes.Value {
+	p.t.Helper()
+	value, err := dynamicValue.Unmarshal(valueType)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return value
+}
+
+// requireNoErrors fails the test if diags has errors.
+func (p *testProvider) requireNoErrors(step string, diags []*tfprotov6.Diagnostic) {
+	p.t.Helper()
+	requireNoErrors(p.t, step, diags)
+}
+
+func requireNoErrors(t *testing.T, step string, diags []*tfprotov6.Diagnostic) {
+	t.Helper()
+	for _, diag := range diags {
+		if diag.Severity == tfprotov6.DiagnosticSeverityError {
+			t.Fatalf("%s failed: %s: %s", step, diag.Summary, diag.Detail)
+		}
+	}
+}
+
+// requireError fails the test unless diags has an error whose summary or detail matches pattern.
+func requireError(t *testing.T, diags []*tfprotov6.Diagnostic, pattern string) {
+	t.Helper()
+	re := regexp.MustCompile(pattern)
+	for _, diag := range diags {
+		if diag.Severity == tfprotov6.DiagnosticSeverityError && re.MatchString(diag.Summary+"\n"+diag.Detail) {
+			return
+		}
+	}
+	t.Fatalf("expected an error matching %q, got %v", pattern, testDescribeDiagnostics(diags))
+}
+
+// requireWarning fails the test unless diags has a warning whose summary or detail matches pattern.
+func requireWarning(t *testing.T, diags []*tfprotov6.Diagnostic, pattern string) {
+	t.Helper()
+	re := regexp.MustCompile(pattern)
+	for _, diag := range diags {
+		if diag.Severity == tfprotov6.DiagnosticSeverityWarning && re.MatchString(diag.Summary+"\n"+diag.Detail) {
+			return
+		}
+	}
+	t.Fatalf("expected a warning matching %q, got %v", pattern, testDescribeDiagnostics(diags))
+}
+
+func testHasErrors(diags []*tfprotov6.Diagnostic) bool {
+	for _, diag := range diags {
+		if diag.Severity == tfprotov6.DiagnosticSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func testDescribeDiagnostics(diags []*tfprotov6.Diagnostic) []string {
+	descriptions := make([]string, 0, len(diags))
+	for _, diag := range diags {
+		descriptions = append(descriptions, fmt.Sprintf("%s: %s: %s", diag.Severity, diag.Summary, diag.Detail))
+	}
+	return descriptions
+}
+
+// testConsistentWithPlan returns an error unless actual has the known values of planned.
+func testConsistentWithPlan(planned tftypes.Value, actual tftypes.Value) error {
+	if !planned.IsKnown() {
+		return nil
+	}
+	if planned.IsNull() || actual.IsNull() || !actual.IsKnown() {
+		if planned.Equal(actual) {
+			return nil
+		}
+		return fmt.Errorf("planned %s, got %s", planned, actual)
+	}
+	switch planned.Type().(type) {
+	case tftypes.Object, tftypes.Map:
+		var plannedElements, actualElements map[string]tftypes.Value
+		if err := planned.As(&plannedElements); err != nil {
+			return err
+		}
+		if err := actual.As(&actualElements); err != nil {
+			return err
+		}
+		if len(plannedElements) != len(actualElements) {
+			return fmt.Errorf("planned %s, got %s", planned, actual)
+		}
+		for key, plannedElement := range plannedElements {
+			if err := testConsistentWithPlan(plannedElement, actualElements[key]); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return nil
+	case tftypes.List:
+		var plannedElements, actualElements []tftypes.Value
+		if err := planned.As(&plannedElements); err != nil {
+			return err
+		}
+		if err := actual.As(&actualElements); err != nil {
+			return err
+		}
+		if len(plannedElements) != len(actualElements) {
+			return fmt.Errorf("planned %s, got %s", planned, actual)
+		}
+		for i, plannedElement := range plannedElements {
+			if err := testConsistentWithPlan(plannedElement, actualElements[i]); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		return nil
+	case tftypes.Set:
+		if !planned.IsFullyKnown() {
+			return nil
+		}
+	}
+	if !planned.Equal(actual) {
+		return fmt.Errorf("planned %s, got %s", planned, actual)
+	}
+	return nil
+}
+
+// testPlanValid checks a plan against the configuration with the rules Terraform applies to
+// every plan: attributes are planned as configured, unless the prior value is kept for a
+// configured value or the attribute is computed and not configured, and nested blocks follow
+// the same rules.
+func testPlanValid(block *tfprotov6.SchemaBlock, prior tftypes.Value, config tftypes.Value, planned tftypes.Value) error {
+	var priorAttributes, configAttributes, plannedAttributes map[string]tftypes.Value
+	if !prior.IsNull() {
+		if err := prior.As(&priorAttributes); err != nil {
+			return err
+		}
+	}
+	if err := config.As(&configAttributes); err != nil {
+		return err
+	}
+	if err := planned.As(&plannedAttributes); err != nil {
+		return err
+	}
+	priorValue := func(name string, valueType tftypes.Type) tftypes.Value {
+		if value, ok := priorAttributes[name]; ok {
+			return value
+		}
+		return tftypes.NewValue(valueType, nil)
+	}
+
+	for _, attribute := range block.Attributes {
+		configValue, plannedValue := configAttributes[attribute.Name], plannedAttributes[attribute.Name]
+		priorAttribute := priorValue(attribute.Name, configValue.Type())
+		switch {
+		case plannedValue.Equal(configValue):
+		case !priorAttribute.IsNull() && !configValue.IsNull() && plannedValue.Equal(priorAttribute):
+		case attribute.Computed && (!attribute.Optional || configValue.IsNull()):
+		case configValue.IsNull():
+			return fmt.Errorf("%s: planned value %s for a non-computed attribute", attribute.Name, plannedValue)
+		default:
+			return fmt.Errorf("%s: planned value %s does not match config value %s", attribute.Name, plannedValue, configValue)
+		}
+	}
+	for _, nested := range block.BlockTypes {
+		configValue, plannedValue := configAttributes[nested.TypeName], plannedAttributes[nested.TypeName]
+		if nested.Nesting != tfprotov6.SchemaNestedBlockNestingModeSingle {
+			if !plannedValue.Equal(configValue) {
+				return fmt.Errorf("%s: planned value %s does not match config value %s", nested.TypeName, plannedValue, configValue)
+			}
+			continue
+		}
+		if configValue.IsNull() || plannedValue.IsNull() {
+			if !plannedValue.Equal(configValue) {
+				return fmt.Errorf("%s: planned value %s does not match config value %s", nested.TypeName, plannedValue, configValue)
+			}
+			continue
+		}
+		if err := testPlanValid(nested.Block, priorValue(nested.TypeName, configValue.Type()), configValue, plannedValue); err != nil {
+			return fmt.Errorf("%s.%w", nested.TypeName, err)
+		}
+	}
+	return nil
+}
+
+// proposedNewState merges the prior state into config the way Terraform does before planning:
+// computed attributes that are not configured keep their prior value.
+func proposedNewState(t *testing.T, schema *tfprotov6.Schema, prior tftypes.Value, config tftypes.Value) tftypes.Value {
+	t.Helper()
+	if prior.IsNull() {
+		return config
+	}
+	var priorAttributes, configAttributes map[string]tftypes.Value
+	if err := prior.As(&priorAttributes); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.As(&configAttributes); err != nil {
+		t.Fatal(err)
+	}
+	// configAttributes is shared with config, which must not change
+	proposed := make(map[string]tftypes.Value, len(configAttributes))
+	for name, value := range configAttributes {
+		proposed[name] = value
+	}
+	for _, attribute := range schema.Block.Attributes {
+		if attribute.Computed && configAttributes[attribute.Name].IsNull() {
+			proposed[attribute.Name] = priorAttributes[attribute.Name]
+		}
+	}
+	return tftypes.NewValue(config.Type(), proposed)
+}
+
+// testValue converts a Go value to a value of valueType: strings, numbers and booleans to
+// primitives, slices to lists and sets, and maps to maps and objects whose missing attributes
+// are null. tftypes.Value values are used as they are.
+func testValue(t *testing.T, valueType tftypes.Type, value interface{}) tftypes.Value {
+	t.Helper()
+	if v, ok := value.(tftypes.Value); ok {
+		return v
+	}
+	if value == testUnknown {
+		return tftypes.NewValue(valueType, tftypes.UnknownValue)
+	}
+	if value == nil {
+		return tftypes.NewValue(valueType, nil)
+	}
+
+	v := reflect.ValueOf(value)
+	switch typ := valueType.(type) {
+	case tftypes.List, tftypes.Set:
+		var elementType tftypes.Type
+		if list, ok := typ.(tftypes.List); ok {
+			elementType = list.ElementType
+		} else {
+			elementType = typ.(tftypes.Set).ElementType
+		}
+		elements := make([]tftypes.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elements = append(elements, testValue(t, elementType, v.Index(i).Interface()))
+		}
+		return tftypes.NewValue(valueType, elements)
+	case tftypes.Map:
+		elements := make(map[string]tftypes.Value, v.Len())
+		for _, key := range v.MapKeys() {
+			elements[key.String()] = testValue(t, typ.ElementType, v.MapIndex(key).Interface())
+		}
+		return tftypes.NewValue(valueType, elements)
+	case tftypes.Object:
+		attributes, ok := value.(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected map[string]interface{} for %s, got %T", valueType, value)
+		}
+		values := make(map[string]tftypes.Value, len(typ.AttributeTypes))
+		for name, attributeType := range typ.AttributeTypes {
+			values[name] = testValue(t, attributeType, attributes[name])
+		}
+		for name := range attributes {
+			if _, ok := typ.AttributeTypes[name]; !ok {
+				t.Fatalf("unknown attribute %s", name)
+			}
+		}
+		return tftypes.NewValue(valueType, values)
+	}
+
+	switch {
+	case valueType.Equal(tftypes.Number):
+		switch n := value.(type) {
+		case int:
+			return tftypes.NewValue(valueType, big.NewFloat(float64(n)))
+		case float64:
+			return tftypes.NewValue(valueType, big.NewFloat(n))
+		}
+	case valueType.Equal(tftypes.String), valueType.Equal(tftypes.Bool):
+		return tftypes.NewValue(valueType, value)
+	}
+	t.Fatalf("unable to convert %T to %s", value, valueType)
+	return tftypes.Value{}
+}
+
+// testUnknown stands for an unknown value in configurations, e.g. an attribute computed from
+// another resource.
+const testUnknown = testUnknownValue("unknown")
+
+type testUnknownValue string
+
+// testAttribute returns the attribute of an object value at path, whose steps are attribute
+// names, map keys or list indexes.
+func testAttribute(t *testing.T, value tftypes.Value, steps ...interface{}) tftypes.Value {
+	t.Helper()
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			var elements map[string]tftypes.Value
+			if err := value.As(&elements); err != nil {
+				t.Fatalf("unable to get %s of %s: %s", s, value, err)
+			}
+			element, ok := elements[s]
+			if !ok {
+				t.Fatalf("%s has no %s", value, s)
+			}
+			value = element
+		case int:
+			var elements []tftypes.Value
+			if err := value.As(&elements); err != nil {
+				t.Fatalf("unable to get element %d of %s: %s", s, value, err)
+			}
+			if s >= len(elements) {
+				t.Fatalf("%s has no element %d", value, s)
+			}
+			value = elements[s]
+		}
+	}
+	return value
+}
+
+// testWithAttribute returns a copy of an object value with a top-level attribute set to value,
+// converted as described in testValue.
+func testWithAttribute(t *testing.T, object tftypes.Value, name string, value interface{}) tftypes.Value {
+	t.Helper()
+	var values map[string]tftypes.Value
+	if err := object.As(&values); err != nil {
+		t.Fatal(err)
+	}
+	attributeType, ok := object.Type().(tftypes.Object).AttributeTypes[name]
+	if !ok {
+		t.Fatalf("unknown attribute %s", name)
+	}
+	// values is shared with object, which must not change
+	attributes := make(map[string]tftypes.Value, len(values))
+	for attributeName, attributeValue := range values {
+		attributes[attributeName] = attributeValue
+	}
+	attributes[name] = testValue(t, attributeType, value)
+	return tftypes.NewValue(object.Type(), attributes)
+}
+
+// testString returns the string attribute of an object value at path, or "" if it is null.
+func testString(t *testing.T, value tftypes.Value, steps ...interface{}) string {
+	t.Helper()
+	attribute := testAttribute(t, value, steps...)
+	var s string
+	if attribute.IsNull() {
+		return s
+	}
+	if err := attribute.As(&s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testStrings returns the elements of a list or set of strings attribute, sorted.
+func testStrings(t *testing.T, value tftypes.Value, steps ...interface{}) []string {
+	t.Helper()
+	var elements []tftypes.Value
+	if err := testAttribute(t, value, steps...).As(&elements); err != nil {
+		t.Fatal(err)
+	}
+	strs := make([]string, 0, len(elements))
+	for _, element := range elements {
+		var s string
+		if err := element.As(&s); err != nil {
+			t.Fatal(err)
+		}
+		strs = append(strs, s)
+	}
+	sort.Strings(strs)
+	return strs
+}
@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ planmodifier.String = &PublicKeySemanticEquality{}
+var _ planmodifier.String = &NoPrivateKeyForPublicKey{}
+
+// parsePublicKey parses a single public key in authorized_keys format, e.g.
+// "ssh-ed25519 AAAA... comment". Leading and trailing whitespace is ignored, options are not allowed.
+func parsePublicKey(publicKey string) (ssh.PublicKey, error) {
+	key, _, options, rest, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(options) > 0 {
+		return nil, fmt.Errorf("options such as %q are not allowed", options[0])
+	}
+	if strings.TrimSpace(string(rest)) != "" {
+		return nil, fmt.Errorf("only one public key is allowed")
+	}
+	return key, nil
+}
+
+// publicKeysEqual reports whether two public keys are the same key, regardless of whitespace
+// and comments.
+func publicKeysEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	keyA, err := parsePublicKey(a)
+	if err != nil {
+		return false
+	}
+	keyB, err := parsePublicKey(b)
+	if err != nil {
+		return false
+	}
+	return keyA.Type() == keyB.Type() && string(keyA.Marshal()) == string(keyB.Marshal())
+}
+
+// matchesFingerprint reports whether fingerprint is the SHA256 or the MD5 fingerprint of
+// publicKey, as printed by ssh-keygen -l, with or without the "MD5:" prefix.
+func matchesFingerprint(publicKey string, fingerprint string) bool {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+	return fingerprint == ssh.FingerprintSHA256(key) ||
+		strings.TrimPrefix(fingerprint, "MD5:") == ssh.FingerprintLegacyMD5(key)
+}
+
+// publicKeyAttributes returns the fingerprints and type of publicKey, or null values if it
+// cannot be parsed, e.g. for keys added to the account before they were validated.
+func publicKeyAttributes(publicKey string) (fingerprintSHA256 types.String, fingerprintMD5 types.String, keyType types.String) {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return types.StringNull(), types.StringNull(), types.StringNull()
+	}
+	return types.StringValue(ssh.FingerprintSHA256(key)), types.StringValue(ssh.FingerprintLegacyMD5(key)), types.StringValue(key.Type())
+}
+
+// PublicKeySemanticEquality is a plan modifier that keeps the prior public key when the
+// configured one is the same key, so that whitespace and comment changes do not cause a diff.
+type PublicKeySemanticEquality struct{}
+
+func (m PublicKeySemanticEquality) Description(ctx context.Context) string {
+	return "Ignores whitespace and comment changes of the public key"
+}
+
+func (m PublicKeySemanticEquality) MarkdownDescription(ctx context.Context) string {
+	return "Ignores whitespace and comment changes of the public key"
+}
+
+func (m PublicKeySemanticEquality) PlanModifyString(ctx context.Context, request planmodifier.StringRequest, response *planmodifier.StringResponse) {
+	if request.StateValue.IsNull() || request.PlanValue.IsNull() || request.PlanValue.IsUnknown() {
+		return
+	}
+	if publicKeysEqual(request.PlanValue.ValueString(), request.StateValue.ValueString()) {
+		response.PlanValue = request.StateValue
+	}
+}
+
+// NoPrivateKeyForPublicKey is a plan modifier that plans a null private key when the public key
+// is configured, since Lambda Labs only returns one for the key pairs it generates. Otherwise the
+// private key would be unknown whenever the configured public key changes, even by a comment.
+type NoPrivateKeyForPublicKey struct{}
+
+func (m NoPrivateKeyForPublicKey) Description(ctx context.Context) string {
+	return "Null when public_key is configured"
+}
+
+func (m NoPrivateKeyForPublicKey) MarkdownDescription(ctx context.Context) string {
+	return "Null when `public_key` is configured"
+}
+
+func (m NoPrivateKeyForPublicKey) PlanModifyString(ctx context.Context, request planmodifier.StringRequest, response *planmodifier.StringResponse) {
+	var publicKey types.String
+	response.Diagnostics.Append(request.Config.GetAttribute(ctx, path.Root("public_key"), &publicKey)...)
+	if !publicKey.IsNull() {
+		response.PlanValue = types.StringNull()
+	}
+}
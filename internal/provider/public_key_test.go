@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	tests := map[string]bool{
+		testAccPublicKey:        true,
+		testAccPublicKey + "\n": true,
+		"  " + testAccPublicKey: true,
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGcQYOS6lHnQ5EBcLvAdmuM7UTn0yuupDWR+8Ng0jkTZ": true,
+		"":                                 false,
+		"ssh-ed25519 AAAA":                 false,
+		"not a public key":                 false,
+		`command="ls" ` + testAccPublicKey: false,
+		testAccPublicKey + "\n" + testAccOtherPublicKey: false,
+	}
+	for publicKey, valid := range tests {
+		if _, err := parsePublicKey(publicKey); (err == nil) != valid {
+			t.Errorf("parsePublicKey(%q) returned error %v, expected valid %t", publicKey, err, valid)
+		}
+	}
+}
+
+func TestPublicKeysEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{testAccPublicKey, testAccPublicKey, true},
+		{testAccPublicKey, testAccPublicKey + "\n", true},
+		{testAccPublicKey, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGcQYOS6lHnQ5EBcLvAdmuM7UTn0yuupDWR+8Ng0jkTZ laptop", true},
+		{testAccPublicKey, testAccOtherPublicKey, false},
+		{testAccPublicKey, "not a public key", false},
+	}
+	for _, test := range tests {
+		if actual := publicKeysEqual(test.a, test.b); actual != test.equal {
+			t.Errorf("publicKeysEqual(%q, %q) = %t, expected %t", test.a, test.b, actual, test.equal)
+		}
+	}
+}
+
+func TestPublicKeyAttributes(t *testing.T) {
+	fingerprintSHA256, fingerprintMD5, keyType := publicKeyAttributes(testAccPublicKey)
+	if fingerprintSHA256.ValueString() != "SHA256:7TQ++yuZ5QnCIfOnS0xvaujqNlq/wvqSWw3gyqz04TQ" {
+		t.Errorf("unexpected SHA256 fingerprint %s", fingerprintSHA256)
+	}
+	if fingerprintMD5.ValueString() != "50:c6:a1:86:60:26:f3:a3:83:dd:9f:a8:b8:ab:c6:dd" {
+		t.Errorf("unexpected MD5 fingerprint %s", fingerprintMD5)
+	}
+	if keyType.ValueString() != "ssh-ed25519" {
+		t.Errorf("unexpected key type %s", keyType)
+	}
+
+	fingerprintSHA256, fingerprintMD5, keyType = publicKeyAttributes("not a public key")
+	if !fingerprintSHA256.IsNull() || !fingerprintMD5.IsNull() || !keyType.IsNull() {
+		t.Errorf("expected null attributes for an invalid public key")
+	}
+
+	for _, fingerprint := range []string{
+		"SHA256:7TQ++yuZ5QnCIfOnS0xvaujqNlq/wvqSWw3gyqz04TQ",
+		"MD5:50:c6:a1:86:60:26:f3:a3:83:dd:9f:a8:b8:ab:c6:dd",
+		"50:c6:a1:86:60:26:f3:a3:83:dd:9f:a8:b8:ab:c6:dd",
+	} {
+		if !matchesFingerprint(testAccPublicKey, fingerprint) {
+			t.Errorf("expected %s to match", fingerprint)
+		}
+		if matchesFingerprint(testAccOtherPublicKey, fingerprint) {
+			t.Errorf("expected %s not to match another key", fingerprint)
+		}
+	}
+}
+
+func TestPublicKeySemanticEquality(t *testing.T) {
+	tests := map[string]struct {
+		state    types.String
+		plan     types.String
+		expected types.String
+	}{
+		"comment changed": {
+			state:    types.StringValue(testAccPublicKey),
+			plan:     types.StringValue("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGcQYOS6lHnQ5EBcLvAdmuM7UTn0yuupDWR+8Ng0jkTZ laptop\n"),
+			expected: types.StringValue(testAccPublicKey),
+		},
+		"key changed": {
+			state:    types.StringValue(testAccPublicKey),
+			plan:     types.StringValue(testAccOtherPublicKey),
+			expected: types.StringValue(testAccOtherPublicKey),
+		},
+		"create": {
+			state:    types.StringNull(),
+			plan:     types.StringValue(testAccPublicKey),
+			expected: types.StringValue(testAccPublicKey),
+		},
+		"unknown": {
+			state:    types.StringValue(testAccPublicKey),
+			plan:     types.StringUnknown(),
+			expected: types.StringUnknown(),
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			request := planmodifier.StringRequest{
+				Path:        path.Root("public_key"),
+				ConfigValue: test.plan,
+				StateValue:  test.state,
+				PlanValue:   test.plan,
+			}
+			response := &planmodifier.StringResponse{PlanValue: test.plan}
+			PublicKeySemanticEquality{}.PlanModifyString(context.Background(), request, response)
+			if !response.PlanValue.Equal(test.expected) {
+				t.Errorf("expected plan value %s, got %s", test.expected, response.PlanValue)
+			}
+		})
+	}
+}
+
+func TestPublicKeyValidator(t *testing.T) {
+	for publicKey, valid := range map[string]bool{
+		testAccPublicKey:   true,
+		"not a public key": false,
+	} {
+		request := validator.StringRequest{
+			Path:        path.Root("public_key"),
+			ConfigValue: types.StringValue(publicKey),
+		}
+		response := &validator.StringResponse{}
+		PublicKeyValidator{}.ValidateString(context.Background(), request, response)
+		if response.Diagnostics.HasError() == valid {
+			t.Errorf("unexpected diagnostics for %q: %v", publicKey, response.Diagnostics)
+		}
+	}
+}
@@ -5,14 +5,16 @@ import (
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"terraform-provider-lambdalabs/pgk/lambdalabs"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
 var (
-	_ datasource.DataSource              = &sshkeyDataSource{}
-	_ datasource.DataSourceWithConfigure = &sshkeyDataSource{}
+	_ datasource.DataSource                   = &sshkeyDataSource{}
+	_ datasource.DataSourceWithConfigure      = &sshkeyDataSource{}
+	_ datasource.DataSourceWithValidateConfig = &sshkeyDataSource{}
 )
 
 // NewSSHKeyDataSource is a helper function to simplify the provider implementation.
@@ -22,7 +24,7 @@ func NewSSHKeyDataSource() datasource.DataSource {
 
 // sshkeyDataSource is the data source implementation.
 type sshkeyDataSource struct {
-	client *lambdalabs.ClientWithResponses
+	sshKeys lambdalabs.SSHKeysService
 }
 
 func (d *sshkeyDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -33,8 +35,13 @@ func (d *sshkeyDataSource) Schema(_ context.Context, _ datasource.SchemaRequest,
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
 			"name": schema.StringAttribute{
-				Description: "SSHKey name",
-				Required:    true,
+				Description: "SSHKey name. Either name or fingerprint must be set",
+				Optional:    true,
+				Computed:    true,
+			},
+			"fingerprint": schema.StringAttribute{
+				Description: "SHA256 or MD5 fingerprint of the SSHKey to look up, as printed by ssh-keygen -l. Either name or fingerprint must be set",
+				Optional:    true,
 			},
 			"id": schema.StringAttribute{
 				Computed:    true,
@@ -44,27 +51,66 @@ func (d *sshkeyDataSource) Schema(_ context.Context, _ datasource.SchemaRequest,
 				Computed:    true,
 				Description: "SSHKey public key",
 			},
+			"fingerprint_sha256": schema.StringAttribute{
+				Computed:    true,
+				Description: "SHA256 fingerprint of the public key",
+			},
+			"fingerprint_md5": schema.StringAttribute{
+				Computed:    true,
+				Description: "MD5 fingerprint of the public key",
+			},
+			"key_type": schema.StringAttribute{
+				Computed:    true,
+				Description: "Type of the public key, e.g. ssh-ed25519",
+			},
 		},
 	}
 }
 
-// Configure adds the provider configured client to the data source.
+func (d *sshkeyDataSource) ValidateConfig(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	var name types.String
+	var fingerprint types.String
+
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("name"), &name)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("fingerprint"), &fingerprint)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if name.IsNull() && fingerprint.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("name"),
+			"Missing name",
+			"Either name or fingerprint must be set.",
+		)
+	}
+	if !name.IsNull() && !fingerprint.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("fingerprint"),
+			"Conflicting name and fingerprint",
+			"name cannot be set together with fingerprint.",
+		)
+	}
+}
+
+// Configure adds the provider configured services to the data source.
 func (d *sshkeyDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
 	if req.ProviderData == nil {
 		return
 	}
 
-	client, ok := req.ProviderData.(*lambdalabs.ClientWithResponses)
+	services, ok := req.ProviderData.(*lambdalabs.Services)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *lambdalabs.Client, got %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *lambdalabs.Services, got %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.sshKeys = services.SSHKeys
 }
 
 // Read refreshes the Terraform state with the latest data.
@@ -78,54 +124,36 @@ func (d *sshkeyDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
-	if state.Name.IsUnknown() {
+	if state.Name.IsUnknown() || state.Fingerprint.IsUnknown() {
 		resp.Diagnostics.AddError(
 			"Unable to Read Lambda Labs SSHKeys",
-			"SSHKey name is required",
+			"SSHKey name or fingerprint is required",
 		)
 		return
 	}
 
-	r, err := d.client.ListSSHKeysWithResponse(ctx)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs SSHKeys",
-			err.Error(),
-		)
-		return
+	var sshkey lambdalabs.SshKey
+	var err error
+	if state.Fingerprint.IsNull() {
+		sshkey, err = d.sshKeys.FindByName(ctx, state.Name.ValueString())
+	} else {
+		sshkey, err = d.findByFingerprint(ctx, state.Fingerprint.ValueString())
 	}
-	switch r.StatusCode() {
-	case 403, 401:
+	if lambdalabs.IsNotFound(err) {
 		resp.Diagnostics.AddError(
 			"Unable to Read Lambda Labs SSHKeys",
-			"Invalid API Key",
-		)
-	}
-	if r.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs SSHKeys",
-			"Response body is empty",
+			"SSHKey not found",
 		)
 		return
 	}
-
-	keyIndex := -1
-	for index, sshkey := range r.JSON200.Data {
-		name := state.Name.ValueString()
-		if name == sshkey.Name {
-			keyIndex = index
-			state.ID = types.StringValue(sshkey.Id)
-			state.PublicKey = types.StringValue(sshkey.PublicKey)
-			break
-		}
-	}
-	if keyIndex == -1 {
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs SSHKeys",
-			"SSHKey not found",
-		)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Unable to Read Lambda Labs SSHKeys", err, nil)...)
 		return
 	}
+	state.ID = types.StringValue(sshkey.Id)
+	state.Name = types.StringValue(sshkey.Name)
+	state.PublicKey = types.StringValue(sshkey.PublicKey)
+	state.FingerprintSHA256, state.FingerprintMD5, state.KeyType = publicKeyAttributes(sshkey.PublicKey)
 
 	// Set state
 	diags := resp.State.Set(ctx, &state)
@@ -134,3 +162,17 @@ func (d *sshkeyDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 }
+
+// findByFingerprint returns the SSH key whose public key has the given SHA256 or MD5 fingerprint.
+func (d *sshkeyDataSource) findByFingerprint(ctx context.Context, fingerprint string) (lambdalabs.SshKey, error) {
+	sshKeys, err := d.sshKeys.List(ctx)
+	if err != nil {
+		return lambdalabs.SshKey{}, err
+	}
+	for _, sshKey := range sshKeys {
+		if matchesFingerprint(sshKey.PublicKey, fingerprint) {
+			return sshKey, nil
+		}
+	}
+	return lambdalabs.SshKey{}, fmt.Errorf("no SSH key with fingerprint %q: %w", fingerprint, lambdalabs.ErrNotFound)
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccSSHKeyDataSource(t *testing.T) {
+	server := testAccFakeServer(t)
+	sshKey := server.AddSSHKey("acceptance-test", testAccPublicKey)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig + `
+data "lambdalabs_ssh_key" "test" {
+  name = "acceptance-test"
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("data.lambdalabs_ssh_key.test", tfjsonpath.New("id"), knownvalue.StringExact(sshKey.Id)),
+					statecheck.ExpectKnownValue("data.lambdalabs_ssh_key.test", tfjsonpath.New("public_key"), knownvalue.StringExact(testAccPublicKey)),
+					statecheck.ExpectKnownValue("data.lambdalabs_ssh_key.test", tfjsonpath.New("fingerprint_sha256"), knownvalue.StringExact("SHA256:7TQ++yuZ5QnCIfOnS0xvaujqNlq/wvqSWw3gyqz04TQ")),
+					statecheck.ExpectKnownValue("data.lambdalabs_ssh_key.test", tfjsonpath.New("key_type"), knownvalue.StringExact("ssh-ed25519")),
+				},
+			},
+			// Lookup by SHA256 and by MD5 fingerprint
+			{
+				Config: testAccProviderConfig + `
+data "lambdalabs_ssh_key" "test" {
+  fingerprint = "SHA256:7TQ++yuZ5QnCIfOnS0xvaujqNlq/wvqSWw3gyqz04TQ"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.lambdalabs_ssh_key.test", "id", sshKey.Id),
+					resource.TestCheckResourceAttr("data.lambdalabs_ssh_key.test", "name", "acceptance-test"),
+				),
+			},
+			{
+				Config: testAccProviderConfig + `
+data "lambdalabs_ssh_key" "test" {
+  fingerprint = "MD5:50:c6:a1:86:60:26:f3:a3:83:dd:9f:a8:b8:ab:c6:dd"
+}
+`,
+				Check: resource.TestCheckResourceAttr("data.lambdalabs_ssh_key.test", "id", sshKey.Id),
+			},
+			{
+				Config: testAccProviderConfig + `
+data "lambdalabs_ssh_key" "test" {
+  name        = "acceptance-test"
+  fingerprint = "SHA256:7TQ++yuZ5QnCIfOnS0xvaujqNlq/wvqSWw3gyqz04TQ"
+}
+`,
+				ExpectError: regexp.MustCompile(`Conflicting name and fingerprint`),
+			},
+			{
+				Config: testAccProviderConfig + `
+data "lambdalabs_ssh_key" "test" {
+  name = "missing"
+}
+`,
+				ExpectError: regexp.MustCompile(`SSHKey not found`),
+			},
+		},
+	})
+}
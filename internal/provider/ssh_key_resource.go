@@ -5,22 +5,43 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
 	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
+const (
+	// sshKeyImportNamePrefix marks import IDs that refer to an SSH key by name.
+	sshKeyImportNamePrefix = "name:"
+
+	// defaultSSHKeyDeleteTimeout bounds the wait for instances using the key to terminate.
+	defaultSSHKeyDeleteTimeout = 10 * time.Minute
+)
+
+// sshKeyDeletePollInterval is how often deleting a key in use is retried. Tests shorten it.
+var sshKeyDeletePollInterval = defaultPollInterval
+
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &SshKeyResource{}
 var _ resource.ResourceWithConfigure = &SshKeyResource{}
 var _ resource.ResourceWithImportState = &SshKeyResource{}
+var _ resource.ResourceWithValidateConfig = &SshKeyResource{}
 
 func NewSSHKeyResource() resource.Resource {
 	return &SshKeyResource{}
@@ -28,7 +49,8 @@ func NewSSHKeyResource() resource.Resource {
 
 // SshKeyResource defines the resource implementation.
 type SshKeyResource struct {
-	client *lambdalabs.ClientWithResponses
+	sshKeys   lambdalabs.SSHKeysService
+	instances lambdalabs.InstancesService
 }
 
 func (r *SshKeyResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -56,13 +78,85 @@ func (r *SshKeyResource) Schema(ctx context.Context, req resource.SchemaRequest,
 				},
 			},
 			"public_key": schema.StringAttribute{
-				MarkdownDescription: "SSH Key Public Key",
-				Required:            true,
+				MarkdownDescription: "SSH Key Public Key, in authorized_keys format. Whitespace and comment changes are ignored. " +
+					"If omitted, Lambda Labs generates a new key pair and its private key is stored in `private_key`",
+				Optional: true,
+				Computed: true,
+				Validators: []validator.String{
+					PublicKeyValidator{},
+				},
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					PublicKeySemanticEquality{},
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"fingerprint_sha256": schema.StringAttribute{
+				MarkdownDescription: "SHA256 fingerprint of the public key, as printed by `ssh-keygen -l`, e.g. `SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8`",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"fingerprint_md5": schema.StringAttribute{
+				MarkdownDescription: "MD5 fingerprint of the public key, as printed by `ssh-keygen -l -E md5` without the `MD5:` prefix",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"key_type": schema.StringAttribute{
+				MarkdownDescription: "Type of the public key, e.g. `ssh-ed25519` or `ssh-rsa`",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"private_key": schema.StringAttribute{
+				MarkdownDescription: "Private key of the generated key pair, in PEM format. Only set when `public_key` is omitted, and only known after the key is created",
+				Computed:            true,
+				Sensitive:           true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					NoPrivateKeyForPublicKey{},
+				},
+			},
+			"private_key_file": schema.StringAttribute{
+				MarkdownDescription: "Path of a file to write the private key of the generated key pair to, with 0400 permissions. " +
+					"The file is removed when the key is destroyed, and the key is replaced if the file goes missing. Cannot be set together with `public_key`",
+				Optional: true,
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
 		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Delete: true,
+				DeleteDescription: "How long to keep retrying the deletion while instances using the key finish terminating, " +
+					"as a duration string such as `10m`. Defaults to `10m`.",
+			}),
+		},
+	}
+}
+
+func (r *SshKeyResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var publicKey types.String
+	var privateKeyFile types.String
+
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("public_key"), &publicKey)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("private_key_file"), &privateKeyFile)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !publicKey.IsNull() && !privateKeyFile.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("private_key_file"),
+			"Conflicting public_key and private_key_file",
+			"private_key_file can only be set when public_key is omitted, so that Lambda Labs generates the key pair.",
+		)
 	}
 }
 
@@ -72,17 +166,18 @@ func (r *SshKeyResource) Configure(ctx context.Context, req resource.ConfigureRe
 		return
 	}
 
-	client, ok := req.ProviderData.(*lambdalabs.ClientWithResponses)
+	services, ok := req.ProviderData.(*lambdalabs.Services)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *lambdalabs.ClientWithResponses, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *lambdalabs.Services, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
 
-	r.client = client
+	r.sshKeys = services.SSHKeys
+	r.instances = services.Instances
 }
 
 func (r *SshKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
@@ -100,25 +195,26 @@ func (r *SshKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 	}
 
 	body := lambdalabs.AddSSHKeyJSONRequestBody{
-		Name:      data.Name.ValueString(),
-		PublicKey: data.PublicKey.ValueStringPointer(),
+		Name: data.Name.ValueString(),
 	}
-	response, err := r.client.AddSSHKeyWithResponse(ctx, body)
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to create ssh_key, got error: %s", err))
-		return
+	// public_key is unknown when omitted, since it is computed, in which case Lambda Labs
+	// generates the key pair
+	if !data.PublicKey.IsNull() && !data.PublicKey.IsUnknown() {
+		body.PublicKey = data.PublicKey.ValueStringPointer()
 	}
-	if response.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Failed to create SSH Key",
-			fmt.Sprintf("Unable to create ssh_key, got error: %s", response.Body),
-		)
+	sshKey, err := r.sshKeys.Add(ctx, body)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Failed to create SSH Key", err, addSSHKeyFieldPaths)...)
 		return
 	}
 
-	data.Name = types.StringValue(response.JSON200.Data.Name)
-	data.PublicKey = types.StringValue(response.JSON200.Data.PublicKey)
-	data.Id = types.StringValue(response.JSON200.Data.Id)
+	data.Name = types.StringValue(sshKey.Name)
+	if !publicKeysEqual(data.PublicKey.ValueString(), sshKey.PublicKey) {
+		data.PublicKey = types.StringValue(sshKey.PublicKey)
+	}
+	data.Id = types.StringValue(sshKey.Id)
+	data.PrivateKey = types.StringPointerValue(sshKey.PrivateKey)
+	data.FingerprintSHA256, data.FingerprintMD5, data.KeyType = publicKeyAttributes(sshKey.PublicKey)
 
 	// Write logs using the tflog package
 	// Documentation: https://terraform.io/plugin/log
@@ -126,6 +222,27 @@ func (r *SshKeyResource) Create(ctx context.Context, req resource.CreateRequest,
 
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+
+	// The key exists from here on, so it is saved even if the file cannot be written, and
+	// replaced on the next apply since the file will be missing.
+	if privateKeyFile := data.PrivateKeyFile.ValueString(); privateKeyFile != "" {
+		if sshKey.PrivateKey == nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("private_key_file"),
+				"Missing private key",
+				"Lambda Labs did not return a private key for the generated key pair. Please report this issue to the provider developers.",
+			)
+			return
+		}
+		if err := writePrivateKeyFile(privateKeyFile, *sshKey.PrivateKey); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("private_key_file"),
+				"Failed to write private key file",
+				fmt.Sprintf("Unable to write the private key of SSH Key %s, got error: %s", sshKey.Id, err),
+			)
+			return
+		}
+	}
 }
 
 func (r *SshKeyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
@@ -138,31 +255,31 @@ func (r *SshKeyResource) Read(ctx context.Context, req resource.ReadRequest, res
 		return
 	}
 
-	response, err := r.client.ListSSHKeysWithResponse(ctx)
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to read ssh_key, got error: %s", err))
+	sshKey, err := r.sshKeys.Get(ctx, data.Id.ValueString())
+	if lambdalabs.IsNotFound(err) {
+		// Keys deleted out of band drop out of state, so that Terraform plans to re-create them
+		tflog.Warn(ctx, "SSH Key no longer exists, removing it from state", map[string]interface{}{"id": data.Id.ValueString()})
+		resp.State.RemoveResource(ctx)
 		return
 	}
-	if response.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Failed to read SSH Key",
-			fmt.Sprintf("Unable to read ssh_key, got error: %s", response.Body),
-		)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Failed to read SSH Key", err, nil)...)
 		return
 	}
 
-	keyIndex := -1
-	for index, sshKey := range response.JSON200.Data {
-		if data.Name.ValueString() == sshKey.Name {
-			keyIndex = index
-			data.Name = types.StringValue(sshKey.Name)
-			data.PublicKey = types.StringValue(sshKey.PublicKey)
-			break
-		}
+	// A public key changed out of band shows up as drift, and replaces the key. The one in state
+	// is kept when it only differs by whitespace or comment, e.g. if the API normalized it.
+	data.Name = types.StringValue(sshKey.Name)
+	if !publicKeysEqual(data.PublicKey.ValueString(), sshKey.PublicKey) {
+		data.PublicKey = types.StringValue(sshKey.PublicKey)
 	}
-	if keyIndex == -1 {
-		tflog.Trace(ctx, "SSH Key not found")
-		return
+	data.FingerprintSHA256, data.FingerprintMD5, data.KeyType = publicKeyAttributes(sshKey.PublicKey)
+
+	if privateKeyFile := data.PrivateKeyFile.ValueString(); privateKeyFile != "" {
+		if _, err := os.Stat(privateKeyFile); errors.Is(err, fs.ErrNotExist) {
+			tflog.Info(ctx, "Private key file is missing, the SSH Key will be replaced", map[string]interface{}{"private_key_file": privateKeyFile})
+			data.PrivateKeyFile = types.StringNull()
+		}
 	}
 
 	// Save updated data into Terraform state
@@ -183,22 +300,175 @@ func (r *SshKeyResource) Delete(ctx context.Context, req resource.DeleteRequest,
 		return
 	}
 
-	response, err := r.client.DeleteSSHKeyWithResponse(ctx, data.Id.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError("Client Error", fmt.Sprintf("Unable to delete ssh_key, got error: %s", err))
+	deleteTimeout, diags := data.Timeouts.Delete(ctx, defaultSSHKeyDeleteTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-	if response.StatusCode() != 200 {
-		resp.Diagnostics.AddError(
-			"Failed to delete SSH Key",
-			fmt.Sprintf("Unable to delete ssh_key, got error: %s", response.Body),
-		)
+
+	resp.Diagnostics.Append(deleteSSHKey(ctx, r.sshKeys, r.instances, data.Id.ValueString(), data.Name.ValueString(), deleteTimeout)...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	tflog.Info(ctx, "Deleted SSH Key", map[string]interface{}{"id": data.Id})
+
+	if privateKeyFile := data.PrivateKeyFile.ValueString(); privateKeyFile != "" {
+		if err := os.Remove(privateKeyFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			resp.Diagnostics.AddAttributeWarning(
+				path.Root("private_key_file"),
+				"Failed to remove private key file",
+				fmt.Sprintf("SSH Key %s was deleted, but its private key file could not be removed, got error: %s", data.Id.ValueString(), err),
+			)
+		}
+	}
 }
 
+// ImportState adopts an existing SSH key, identified either by its ID or by "name:<key-name>".
+// The private key of an imported key is unknown, so private_key stays null.
 func (r *SshKeyResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	sshKeyId := req.ID
+	if strings.HasPrefix(req.ID, sshKeyImportNamePrefix) {
+		name := strings.TrimPrefix(req.ID, sshKeyImportNamePrefix)
+		sshKey, err := r.sshKeys.FindByName(ctx, name)
+		if lambdalabs.IsNotFound(err) {
+			resp.Diagnostics.AddError("Failed to import SSH Key", fmt.Sprintf("No SSH Key is named %q", name))
+			return
+		}
+		if err != nil {
+			resp.Diagnostics.Append(apiErrorDiagnostics("Failed to import SSH Key", err, nil)...)
+			return
+		}
+		sshKeyId = sshKey.Id
+	}
+
+	sshKey, err := r.sshKeys.Get(ctx, sshKeyId)
+	if lambdalabs.IsNotFound(err) {
+		resp.Diagnostics.AddError("Failed to import SSH Key", fmt.Sprintf("SSH Key %s does not exist", sshKeyId))
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Failed to import SSH Key", err, nil)...)
+		return
+	}
+
+	data := SshKeyResourceModel{
+		Id:             types.StringValue(sshKey.Id),
+		Name:           types.StringValue(sshKey.Name),
+		PublicKey:      types.StringValue(sshKey.PublicKey),
+		PrivateKey:     types.StringNull(),
+		PrivateKeyFile: types.StringNull(),
+	}
+	data.FingerprintSHA256, data.FingerprintMD5, data.KeyType = publicKeyAttributes(sshKey.PublicKey)
+
+	// The timeouts block stays null, as it is when omitted from the configuration
+	resp.Diagnostics.Append(resp.State.GetAttribute(ctx, path.Root("timeouts"), &data.Timeouts)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// writePrivateKeyFile writes a private key readable by the current user only. A file left over
+// from a previous key is replaced, even though it is read-only.
+func writePrivateKeyFile(name string, privateKey string) error {
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(name, []byte(privateKey), 0o400)
+}
+
+// deleteSSHKey deletes a key. The API refuses to delete a key while instances use it, including
+// terminating ones, so deleting it right after the instances is retried for up to timeout until
+// they are gone. Keys that no longer exist are not an error.
+func deleteSSHKey(ctx context.Context, sshKeys lambdalabs.SSHKeysService, instances lambdalabs.InstancesService, id string, name string, timeout time.Duration) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var blocking []lambdalabs.Instance
+	var listErr error
+	for inUse := false; ; inUse = true {
+		err := sshKeys.Delete(ctx, id)
+		if err == nil || lambdalabs.IsNotFound(err) {
+			return diags
+		}
+		if inUse && ctx.Err() != nil {
+			diags.AddError("SSH Key in use", sshKeyInUseDetail(name, timeout, blocking, listErr))
+			return diags
+		}
+		if !lambdalabs.IsErrorCode(err, lambdalabs.ErrorCodeSSHKeyInUse) {
+			diags.Append(apiErrorDiagnostics(fmt.Sprintf("Failed to delete SSH Key %q", name), err, nil)...)
+			return diags
+		}
+
+		// The statuses of the instances change while retrying, so they are not read from the cache
+		if usage, err := instancesBySSHKeyName(lambdalabs.WithoutCache(ctx), instances); err != nil {
+			listErr = err
+			tflog.Warn(ctx, "Unable to list the instances using the SSH Key", map[string]interface{}{"error": err.Error()})
+		} else {
+			blocking, listErr = usage[name], nil
+			tflog.Info(ctx, "SSH Key is in use, waiting for instances to terminate", map[string]interface{}{
+				"id":        id,
+				"instances": describeInstances(blocking),
+			})
+		}
+
+		timer := time.NewTimer(sshKeyDeletePollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			diags.AddError("SSH Key in use", sshKeyInUseDetail(name, timeout, blocking, listErr))
+			return diags
+		case <-timer.C:
+		}
+	}
+}
+
+// instancesBySSHKeyName returns the instances that are not terminated yet, by the names of the
+// SSH keys they use.
+func instancesBySSHKeyName(ctx context.Context, instances lambdalabs.InstancesService) (map[string][]lambdalabs.Instance, error) {
+	all, err := instances.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string][]lambdalabs.Instance)
+	for _, instance := range all {
+		if instance.Status == lambdalabs.InstanceStatusTerminated {
+			continue
+		}
+		for _, sshKeyName := range instance.SshKeyNames {
+			usage[sshKeyName] = append(usage[sshKeyName], instance)
+		}
+	}
+	return usage, nil
+}
+
+// describeInstances returns the IDs of instances, along with their name and status, sorted.
+func describeInstances(instances []lambdalabs.Instance) []string {
+	descriptions := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		description := instance.Id
+		if instance.Name != nil && *instance.Name != "" {
+			description = fmt.Sprintf("%s (%s)", description, *instance.Name)
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s, %s", description, instance.Status))
+	}
+	sort.Strings(descriptions)
+	return descriptions
+}
+
+// sshKeyInUseDetail explains which instances kept a key from being deleted, as last seen.
+func sshKeyInUseDetail(name string, timeout time.Duration, blocking []lambdalabs.Instance, listErr error) string {
+	detail := fmt.Sprintf("SSH Key %q is still in use after retrying for %s.", name, timeout)
+	switch {
+	case len(blocking) > 0:
+		detail += " It is used by the following instances:\n\n  - " + strings.Join(describeInstances(blocking), "\n  - ") +
+			"\n\nTerminate them, or increase the delete timeout if they are already terminating."
+	case listErr != nil:
+		detail += fmt.Sprintf(" The instances using it could not be listed, got error: %s", listErr)
+	}
+	return detail
 }
@@ -0,0 +1,660 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"terraform-provider-lambdalabs/internal/fakelambda"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func testAccSSHKeyResourceConfig(name string) string {
+	return testAccSSHKeyResourceConfigWithPublicKey(name, testAccPublicKey)
+}
+
+func testAccSSHKeyResourceConfigWithPublicKey(name string, publicKey string) string {
+	return testAccProviderConfig + fmt.Sprintf(`
+resource "lambdalabs_ssh_key" "test" {
+  name       = %q
+  public_key = %q
+}
+`, name, publicKey)
+}
+
+// testAccCheckSSHKeyExists checks that the SSH key in state exists in the fake API.
+func testAccCheckSSHKeyExists(server *fakelambda.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["lambdalabs_ssh_key.test"]
+		if !ok {
+			return fmt.Errorf("lambdalabs_ssh_key.test not found in state")
+		}
+		for _, sshKey := range server.SSHKeys() {
+			if sshKey.Id == rs.Primary.ID {
+				return nil
+			}
+		}
+		return fmt.Errorf("SSH key %s not found in the API", rs.Primary.ID)
+	}
+}
+
+// testAccSSHKeyID returns the ID of the SSH key named name in the fake API.
+func testAccSSHKeyID(t *testing.T, server *fakelambda.Server, name string) string {
+	t.Helper()
+	for _, sshKey := range server.SSHKeys() {
+		if sshKey.Name == name {
+			return sshKey.Id
+		}
+	}
+	t.Fatalf("SSH key %s not found in the API", name)
+	return ""
+}
+
+func TestAccSSHKeyResource(t *testing.T) {
+	server := testAccFakeServer(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			if sshKeys := server.SSHKeys(); len(sshKeys) > 0 {
+				return fmt.Errorf("expected no SSH keys, got %v", sshKeys)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			// Create and Read testing
+			{
+				Config: testAccSSHKeyResourceConfig("acceptance-test"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key.test", tfjsonpath.New("id"), knownvalue.NotNull()),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key.test", tfjsonpath.New("name"), knownvalue.StringExact("acceptance-test")),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key.test", tfjsonpath.New("public_key"), knownvalue.StringExact(testAccPublicKey)),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key.test", tfjsonpath.New("fingerprint_sha256"), knownvalue.StringExact("SHA256:7TQ++yuZ5QnCIfOnS0xvaujqNlq/wvqSWw3gyqz04TQ")),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key.test", tfjsonpath.New("fingerprint_md5"), knownvalue.StringExact("50:c6:a1:86:60:26:f3:a3:83:dd:9f:a8:b8:ab:c6:dd")),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key.test", tfjsonpath.New("key_type"), knownvalue.StringExact("ssh-ed25519")),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key.test", tfjsonpath.New("private_key"), knownvalue.Null()),
+				},
+				Check: testAccCheckSSHKeyExists(server),
+			},
+			// Whitespace and comment changes of the public key are not a diff
+			{
+				Config: testAccSSHKeyResourceConfigWithPublicKey("acceptance-test",
+					"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGcQYOS6lHnQ5EBcLvAdmuM7UTn0yuupDWR+8Ng0jkTZ laptop\n"),
+				PlanOnly: true,
+			},
+			// Renaming replaces the key
+			{
+				Config: testAccSSHKeyResourceConfig("acceptance-test-renamed"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_ssh_key.test", "name", "acceptance-test-renamed"),
+					testAccCheckSSHKeyExists(server),
+				),
+			},
+			// ImportState testing, by ID and by name
+			{
+				ResourceName:      "lambdalabs_ssh_key.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:      "lambdalabs_ssh_key.test",
+				ImportState:       true,
+				ImportStateId:     "name:acceptance-test-renamed",
+				ImportStateVerify: true,
+			},
+			// A public key changed out of band is drift, and replaces the key
+			{
+				PreConfig: func() {
+					server.SetSSHKeyPublicKey(testAccSSHKeyID(t, server, "acceptance-test-renamed"), testAccOtherPublicKey)
+				},
+				Config: testAccSSHKeyResourceConfig("acceptance-test-renamed"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("lambdalabs_ssh_key.test", plancheck.ResourceActionReplace),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_ssh_key.test", "public_key", testAccPublicKey),
+					testAccCheckSSHKeyExists(server),
+				),
+			},
+			// A key deleted out of band is removed from state, and planned to be re-created
+			{
+				PreConfig: func() {
+					server.DeleteSSHKey(testAccSSHKeyID(t, server, "acceptance-test-renamed"))
+				},
+				RefreshState: true,
+				Check: func(s *terraform.State) error {
+					if _, ok := s.RootModule().Resources["lambdalabs_ssh_key.test"]; ok {
+						return fmt.Errorf("expected the deleted SSH key to be removed from state")
+					}
+					return nil
+				},
+				RefreshPlanChecks: resource.RefreshPlanChecks{
+					PostRefresh: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("lambdalabs_ssh_key.test", plancheck.ResourceActionCreate),
+					},
+				},
+				ExpectNonEmptyPlan: true,
+			},
+			// Delete testing automatically occurs in TestCase
+		},
+	})
+}
+
+// TestSSHKeyResourceLifecycle runs TestAccSSHKeyResource without the Terraform CLI.
+func TestSSHKeyResourceLifecycle(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	p := newTestProvider(t, server)
+	config := map[string]interface{}{
+		"name":       "acceptance-test",
+		"public_key": testAccPublicKey,
+	}
+
+	state, diags := p.apply("lambdalabs_ssh_key", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key"), nil), config)
+	requireNoErrors(t, "Create", diags)
+	sshKeys := server.SSHKeys()
+	if len(sshKeys) != 1 || sshKeys[0].Id != testString(t, state, "id") {
+		t.Fatalf("expected SSH key %s to exist, got %v", testString(t, state, "id"), sshKeys)
+	}
+	refreshed, diags := p.read("lambdalabs_ssh_key", state)
+	requireNoErrors(t, "Read", diags)
+	if !refreshed.Equal(state) {
+		t.Errorf("expected refreshing to keep the state %s, got %s", state, refreshed)
+	}
+
+	_, diags = p.apply("lambdalabs_ssh_key", state, nil)
+	requireNoErrors(t, "Delete", diags)
+	if sshKeys := server.SSHKeys(); len(sshKeys) != 0 {
+		t.Errorf("expected the SSH key to be deleted, got %v", sshKeys)
+	}
+}
+
+func TestAccSSHKeyResourceInvalidPublicKey(t *testing.T) {
+	server := testAccFakeServer(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSSHKeyResourceConfigWithPublicKey("acceptance-test", "ssh-ed25519 not-a-key"),
+				ExpectError: regexp.MustCompile(`Invalid public key`),
+			},
+		},
+	})
+	if calls := server.Calls(fakelambda.OperationAddSSHKey); calls != 0 {
+		t.Errorf("expected the invalid key to be rejected during plan, got %d API calls", calls)
+	}
+}
+
+func TestAccSSHKeyResourceImportErrors(t *testing.T) {
+	testAccFakeServer(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:        testAccSSHKeyResourceConfig("acceptance-test"),
+				ResourceName:  "lambdalabs_ssh_key.test",
+				ImportState:   true,
+				ImportStateId: "name:missing",
+				ExpectError:   regexp.MustCompile(`No SSH Key is named "missing"`),
+			},
+			{
+				Config:        testAccSSHKeyResourceConfig("acceptance-test"),
+				ResourceName:  "lambdalabs_ssh_key.test",
+				ImportState:   true,
+				ImportStateId: "0920582c7ff041399e34823a0be62549",
+				ExpectError:   regexp.MustCompile(`does not exist`),
+			},
+		},
+	})
+}
+
+func TestAccSSHKeyResourceDuplicateName(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddSSHKey("acceptance-test", testAccPublicKey)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSSHKeyResourceConfig("acceptance-test"),
+				ExpectError: regexp.MustCompile(`already exists`),
+			},
+		},
+	})
+}
+
+func TestSSHKeyResourceRead(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	p := newTestProvider(t, server)
+	config := map[string]interface{}{
+		"name":       "acceptance-test",
+		"public_key": testAccPublicKey,
+	}
+	created, diags := p.apply("lambdalabs_ssh_key", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key"), nil), config)
+	requireNoErrors(t, "Create", diags)
+	id := testString(t, created, "id")
+	p.requireEmptyPlan("lambdalabs_ssh_key", created, config)
+
+	// Whitespace and comment changes in the configuration do not change the key
+	p.requireEmptyPlan("lambdalabs_ssh_key", created, map[string]interface{}{
+		"name":       "acceptance-test",
+		"public_key": "  " + strings.TrimSuffix(testAccPublicKey, "acceptance-test") + "laptop\n",
+	})
+
+	// Every refresh below runs in a new provider, as it would in a new terraform run, so that
+	// out of band changes are not hidden by the list cache of the previous one.
+
+	// A public key that only differs by comment is kept
+	server.SetSSHKeyPublicKey(id, strings.TrimSuffix(testAccPublicKey, "acceptance-test")+"renamed")
+	p = newTestProvider(t, server)
+	state, diags := p.read("lambdalabs_ssh_key", created)
+	requireNoErrors(t, "Read", diags)
+	if publicKey := testString(t, state, "public_key"); publicKey != testAccPublicKey {
+		t.Errorf("expected public_key %q to be kept, got %q", testAccPublicKey, publicKey)
+	}
+
+	// A public key changed out of band shows up as drift, and replaces the key
+	server.SetSSHKeyPublicKey(id, testAccOtherPublicKey)
+	p = newTestProvider(t, server)
+	state, diags = p.read("lambdalabs_ssh_key", created)
+	requireNoErrors(t, "Read", diags)
+	if publicKey := testString(t, state, "public_key"); publicKey != testAccOtherPublicKey {
+		t.Errorf("expected public_key %q, got %q", testAccOtherPublicKey, publicKey)
+	}
+	planned, _ := p.plan("lambdalabs_ssh_key", state, config)
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	if len(planned.RequiresReplace) == 0 {
+		t.Error("expected a changed public key to require replacement")
+	}
+
+	// A key deleted out of band is removed from state
+	server.DeleteSSHKey(id)
+	p = newTestProvider(t, server)
+	state, diags = p.read("lambdalabs_ssh_key", created)
+	requireNoErrors(t, "Read", diags)
+	if !state.IsNull() {
+		t.Errorf("expected a deleted key to be removed from state, got %s", state)
+	}
+}
+
+func TestSSHKeyResourceImport(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("other", testAccOtherPublicKey)
+	sshKey := server.AddSSHKey("acceptance-test", testAccPublicKey)
+	p := newTestProvider(t, server)
+	config := map[string]interface{}{
+		"name":       "acceptance-test",
+		"public_key": testAccPublicKey,
+	}
+
+	for _, importID := range []string{sshKey.Id, "name:acceptance-test"} {
+		state, diags := p.importState("lambdalabs_ssh_key", importID)
+		requireNoErrors(t, "Import "+importID, diags)
+		if id := testString(t, state, "id"); id != sshKey.Id {
+			t.Errorf("expected %s to import SSH key %s, got %s", importID, sshKey.Id, id)
+		}
+		if publicKey := testString(t, state, "public_key"); publicKey != testAccPublicKey {
+			t.Errorf("expected imported public_key %q, got %q", testAccPublicKey, publicKey)
+		}
+		p.requireEmptyPlan("lambdalabs_ssh_key", state, config)
+	}
+
+	for importID, expected := range map[string]string{
+		"0920582c7ff041399e34823a0be62549": "SSH Key 0920582c7ff041399e34823a0be62549 does not exist",
+		"name:missing":                     `No SSH Key is named "missing"`,
+	} {
+		_, diags := p.importState("lambdalabs_ssh_key", importID)
+		requireError(t, diags, regexp.QuoteMeta(expected))
+	}
+}
+
+func testAccSSHKeyResourceGeneratedConfig(privateKeyFile string) string {
+	return testAccProviderConfig + fmt.Sprintf(`
+resource "lambdalabs_ssh_key" "test" {
+  name             = "acceptance-test-generated"
+  private_key_file = %q
+}
+`, privateKeyFile)
+}
+
+// testAccCheckPrivateKeyFile checks that the private key in state was written to name with
+// 0400 permissions.
+func testAccCheckPrivateKeyFile(name string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["lambdalabs_ssh_key.test"]
+		if !ok {
+			return fmt.Errorf("lambdalabs_ssh_key.test not found in state")
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if info.Mode().Perm() != 0o400 {
+			return fmt.Errorf("expected private key file permissions 0400, got %o", info.Mode().Perm())
+		}
+		privateKey, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if string(privateKey) != rs.Primary.Attributes["private_key"] {
+			return fmt.Errorf("private key file does not match private_key")
+		}
+		return nil
+	}
+}
+
+func TestAccSSHKeyResourceGenerated(t *testing.T) {
+	server := testAccFakeServer(t)
+	privateKeyFile := filepath.Join(t.TempDir(), "id_rsa")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			if _, err := os.Stat(privateKeyFile); !os.IsNotExist(err) {
+				return fmt.Errorf("expected the private key file to be removed, got %v", err)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHKeyResourceGeneratedConfig(privateKeyFile),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestMatchResourceAttr("lambdalabs_ssh_key.test", "public_key", regexp.MustCompile(`^ssh-rsa `)),
+					resource.TestMatchResourceAttr("lambdalabs_ssh_key.test", "private_key", regexp.MustCompile(`PRIVATE KEY`)),
+					testAccCheckSSHKeyExists(server),
+					testAccCheckPrivateKeyFile(privateKeyFile),
+				),
+			},
+			// A missing private key file replaces the key
+			{
+				PreConfig: func() {
+					if err := os.Remove(privateKeyFile); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: testAccSSHKeyResourceGeneratedConfig(privateKeyFile),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccCheckSSHKeyExists(server),
+					testAccCheckPrivateKeyFile(privateKeyFile),
+				),
+			},
+		},
+	})
+}
+
+func TestSSHKeyResourceCreateGenerated(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	p := newTestProvider(t, server)
+	privateKeyFile := filepath.Join(t.TempDir(), "id_rsa")
+
+	state, diags := p.apply("lambdalabs_ssh_key", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key"), nil), map[string]interface{}{
+		"name":             "generated",
+		"private_key_file": privateKeyFile,
+	})
+	requireNoErrors(t, "Create", diags)
+
+	sshKeys := server.SSHKeys()
+	if len(sshKeys) != 1 || sshKeys[0].Id != testString(t, state, "id") {
+		t.Fatalf("expected SSH key %s to exist, got %v", testString(t, state, "id"), sshKeys)
+	}
+	sshKey := sshKeys[0]
+	if !strings.HasPrefix(sshKey.PublicKey, "ssh-rsa ") {
+		t.Errorf("expected Lambda Labs to generate the key pair, got public key %q", sshKey.PublicKey)
+	}
+	if publicKey := testString(t, state, "public_key"); publicKey != sshKey.PublicKey {
+		t.Errorf("expected public_key %q, got %q", sshKey.PublicKey, publicKey)
+	}
+	if testString(t, state, "fingerprint_sha256") == "" {
+		t.Error("expected fingerprint_sha256 to be set")
+	}
+
+	privateKey := testString(t, state, "private_key")
+	if !strings.Contains(privateKey, "PRIVATE KEY") {
+		t.Fatalf("expected private_key to be set, got %q", privateKey)
+	}
+	info, err := os.Stat(privateKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o400 {
+		t.Errorf("expected private key file permissions 0400, got %o", info.Mode().Perm())
+	}
+	written, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != privateKey {
+		t.Error("private key file does not match private_key")
+	}
+}
+
+func TestAccSSHKeyResourceConflictingPrivateKeyFile(t *testing.T) {
+	testAccFakeServer(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig + fmt.Sprintf(`
+resource "lambdalabs_ssh_key" "test" {
+  name             = "acceptance-test"
+  public_key       = %q
+  private_key_file = "id_rsa"
+}
+`, testAccPublicKey),
+				ExpectError: regexp.MustCompile(`Conflicting public_key and private_key_file`),
+			},
+		},
+	})
+}
+
+func TestWritePrivateKeyFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "id_rsa")
+	for _, privateKey := range []string{"first", "second"} {
+		if err := writePrivateKeyFile(name, privateKey); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != privateKey {
+			t.Errorf("expected %q, got %q", privateKey, data)
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o400 {
+			t.Errorf("expected permissions 0400, got %o", info.Mode().Perm())
+		}
+	}
+	if err := writePrivateKeyFile(filepath.Join(name, "nested"), "key"); err == nil {
+		t.Errorf("expected an error writing below a file, got %v", err)
+	}
+}
+
+// testInstancesService returns an instances service of the fake API, to change instances out
+// of band.
+func testInstancesService(t *testing.T, server *fakelambda.Server) lambdalabs.InstancesService {
+	t.Helper()
+	client, err := lambdalabs.NewAuthenticatedClient(server.URL, fakelambda.APIKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lambdalabs.NewServices(client).Instances
+}
+
+// testAccLaunchInstance launches an instance using the SSH key named sshKeyName through the fake
+// API, as if it was launched outside of Terraform.
+func testAccLaunchInstance(t *testing.T, server *fakelambda.Server, name string, sshKeyName string) (string, lambdalabs.InstancesService) {
+	t.Helper()
+	instances := testInstancesService(t, server)
+	ids, err := instances.Launch(context.Background(), lambdalabs.LaunchInstanceJSONRequestBody{
+		InstanceTypeName: "gpu_1x_a10",
+		RegionName:       "us-east-1",
+		SshKeyNames:      []string{sshKeyName},
+		FileSystemNames:  &[]string{},
+		Name:             &name,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids[0], instances
+}
+
+// testAccShortenSSHKeyDeletePollInterval speeds up retries of deleting a key in use.
+func testAccShortenSSHKeyDeletePollInterval(t *testing.T) {
+	pollInterval := sshKeyDeletePollInterval
+	sshKeyDeletePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { sshKeyDeletePollInterval = pollInterval })
+}
+
+func TestAccSSHKeyResourceDeleteWaitsForTerminatingInstances(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.TerminatePolls = 4
+	testAccShortenSSHKeyDeletePollInterval(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			if sshKeys := server.SSHKeys(); len(sshKeys) > 0 {
+				return fmt.Errorf("expected no SSH keys, got %v", sshKeys)
+			}
+			if calls := server.Calls(fakelambda.OperationDeleteSSHKey); calls < 2 {
+				return fmt.Errorf("expected deleting the key in use to be retried, got %d calls", calls)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHKeyResourceConfig("acceptance-test"),
+			},
+			// The key is destroyed while an instance using it is terminating
+			{
+				PreConfig: func() {
+					id, instances := testAccLaunchInstance(t, server, "terminating-instance", "acceptance-test")
+					if _, err := instances.Terminate(context.Background(), []string{id}); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: testAccProviderConfig,
+			},
+		},
+	})
+}
+
+func TestAccSSHKeyResourceDeleteInUseTimeout(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccShortenSSHKeyDeletePollInterval(t)
+	var instanceID string
+
+	config := testAccProviderConfig + fmt.Sprintf(`
+resource "lambdalabs_ssh_key" "test" {
+  name       = "acceptance-test"
+  public_key = %q
+
+  timeouts {
+    delete = "100ms"
+  }
+}
+`, testAccPublicKey)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			// The key cannot be destroyed while an active instance uses it
+			{
+				PreConfig: func() {
+					instanceID, _ = testAccLaunchInstance(t, server, "blocking-instance", "acceptance-test")
+				},
+				Config:      testAccProviderConfig,
+				ExpectError: regexp.MustCompile(`(?s)SSH Key in use.*blocking-instance`),
+			},
+			{
+				PreConfig: func() {
+					if _, ok := server.Instance(instanceID); !ok {
+						t.Fatalf("instance %s not found in the API", instanceID)
+					}
+					server.SetInstanceStatus(instanceID, lambdalabs.InstanceStatusTerminated)
+				},
+				Config: testAccProviderConfig,
+			},
+		},
+	})
+}
+
+func TestSSHKeyResourceDeleteInUseNamesCurrentInstances(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	testAccShortenSSHKeyDeletePollInterval(t)
+	p := newTestProvider(t, server)
+	state, diags := p.apply("lambdalabs_ssh_key", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key"), nil), map[string]interface{}{
+		"name":       "acceptance-test",
+		"public_key": testAccPublicKey,
+		"timeouts":   map[string]interface{}{"delete": "100ms"},
+	})
+	requireNoErrors(t, "Create", diags)
+
+	// The instances are listed, and cached, before the instance using the key is launched
+	_, diags = p.readDataSource("lambdalabs_instances", nil)
+	requireNoErrors(t, "Read instances", diags)
+	testAccLaunchInstance(t, server, "blocking-instance", "acceptance-test")
+
+	_, diags = p.apply("lambdalabs_ssh_key", state, nil)
+	requireError(t, diags, `(?s)SSH Key in use.*blocking-instance`)
+}
+
+func TestSSHKeyInUseDetail(t *testing.T) {
+	name := "trainer"
+	blocking := []lambdalabs.Instance{
+		{Id: "0920582c7ff041399e34823a0be62549", Name: &name, Status: lambdalabs.InstanceStatusTerminating},
+		{Id: "0920582c7ff041399e34823a0be62548", Status: lambdalabs.InstanceStatusActive},
+	}
+
+	detail := sshKeyInUseDetail("laptop", 10*time.Minute, blocking, nil)
+	for _, expected := range []string{
+		`SSH Key "laptop" is still in use after retrying for 10m0s`,
+		"  - 0920582c7ff041399e34823a0be62548, active\n  - 0920582c7ff041399e34823a0be62549 (trainer), terminating",
+	} {
+		if !strings.Contains(detail, expected) {
+			t.Errorf("expected %q in detail, got %q", expected, detail)
+		}
+	}
+
+	detail = sshKeyInUseDetail("laptop", time.Minute, nil, errors.New("connection refused"))
+	if !strings.Contains(detail, "could not be listed, got error: connection refused") {
+		t.Errorf("expected the list error in detail, got %q", detail)
+	}
+}
@@ -0,0 +1,527 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework-timeouts/resource/timeouts"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"sort"
+	"strings"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// sshKeySetID is the ID of every lambdalabs_ssh_key_set, there is one set of keys per account.
+const sshKeySetID = "ssh_keys"
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &SshKeySetResource{}
+var _ resource.ResourceWithConfigure = &SshKeySetResource{}
+var _ resource.ResourceWithValidateConfig = &SshKeySetResource{}
+var _ resource.ResourceWithModifyPlan = &SshKeySetResource{}
+
+func NewSSHKeySetResource() resource.Resource {
+	return &SshKeySetResource{}
+}
+
+// SshKeySetResource manages the SSH keys of the account as a whole.
+type SshKeySetResource struct {
+	sshKeys   lambdalabs.SSHKeysService
+	instances lambdalabs.InstancesService
+}
+
+func (r *SshKeySetResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_ssh_key_set"
+}
+
+func (r *SshKeySetResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Set of SSH keys of the account, from a map of name to public key. Missing keys are added and changed keys replaced. " +
+			"With `exclusive`, keys of the account that are not declared are deleted too, except those used by instances that are not terminated. " +
+			"Only one set should be declared per account, and its keys should not also be managed by `lambdalabs_ssh_key`.",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Always `ssh_keys`",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"keys": schema.MapAttribute{
+				MarkdownDescription: "Public keys in authorized_keys format, by SSH Key name. Keys of the account with the same name and public key, " +
+					"regardless of whitespace and comment, are adopted as they are",
+				ElementType: types.StringType,
+				Required:    true,
+			},
+			"exclusive": schema.BoolAttribute{
+				MarkdownDescription: "Delete the keys of the account that are not in `keys`, see `undeclared_keys`. Defaults to `false`",
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+			},
+			"key_ids": schema.MapAttribute{
+				MarkdownDescription: "SSH Key IDs, by SSH Key name",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"undeclared_keys": schema.SetAttribute{
+				MarkdownDescription: "Names of the keys of the account that are not in `keys`. With `exclusive`, the plan shows which of them are deleted, " +
+					"and only those used by instances that are not terminated remain",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"timeouts": timeouts.Block(ctx, timeouts.Opts{
+				Delete: true,
+				DeleteDescription: "How long to keep retrying the deletion of each key while instances using it finish terminating, " +
+					"as a duration string such as `10m`. Defaults to `10m`.",
+			}),
+		},
+	}
+}
+
+func (r *SshKeySetResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var keys map[string]types.String
+
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("keys"), &keys)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	for name, publicKey := range keys {
+		if publicKey.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("keys").AtMapKey(name), "Missing public key", fmt.Sprintf("SSH Key %q has no public key.", name))
+			continue
+		}
+		if publicKey.IsUnknown() {
+			continue
+		}
+		if _, err := parsePublicKey(publicKey.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("keys").AtMapKey(name),
+				"Invalid public key",
+				fmt.Sprintf("Public key of SSH Key %q must be in authorized_keys format: %s", name, err),
+			)
+		}
+	}
+}
+
+func (r *SshKeySetResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	services, ok := req.ProviderData.(*lambdalabs.Services)
+
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *lambdalabs.Services, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+		return
+	}
+
+	r.sshKeys = services.SSHKeys
+	r.instances = services.Instances
+}
+
+// ModifyPlan keeps key_ids and, without exclusive, undeclared_keys from state while keys does not
+// change. With exclusive, it plans undeclared_keys from the keys of the account, so that the keys
+// it deletes show up in the plan.
+func (r *SshKeySetResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing to plan on destroy, or before the provider is configured
+	if req.Plan.Raw.IsNull() || r.sshKeys == nil {
+		return
+	}
+
+	var plan SshKeySetResourceModel
+	var state SshKeySetResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	managed := make(map[string]types.String)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		resp.Diagnostics.Append(state.Keys.ElementsAs(ctx, &managed, false)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The IDs only change when keys are added, removed or replaced, not for whitespace and
+	// comment changes
+	unchanged := !req.State.Raw.IsNull() && sameSSHKeys(plan.Keys, managed)
+	if unchanged {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("key_ids"), state.KeyIDs)...)
+	}
+
+	if plan.Keys.IsUnknown() || plan.Exclusive.IsUnknown() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("undeclared_keys"), types.SetUnknown(types.StringType))...)
+		return
+	}
+
+	// Without exclusive, undeclared keys are left alone, so they are only refreshed by Read.
+	// Changed keys may fail to be removed and stay in the account, so the value is unknown then.
+	if !plan.Exclusive.ValueBool() {
+		undeclaredKeys := types.SetUnknown(types.StringType)
+		if unchanged && !state.UndeclaredKeys.IsNull() {
+			undeclaredKeys = state.UndeclaredKeys
+		}
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("undeclared_keys"), undeclaredKeys)...)
+		return
+	}
+	declared := plan.Keys.Elements()
+
+	sshKeys, err := r.sshKeys.List(ctx)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Failed to list SSH Keys", err, nil)...)
+		return
+	}
+	usage, err := instancesBySSHKeyName(ctx, r.instances)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Failed to list instances", err, nil)...)
+		return
+	}
+
+	// Keys removed from keys are deleted whether exclusive or not, so they are not undeclared
+	undeclared := make([]string, 0)
+	deleted := make([]string, 0)
+	skipped := make([]string, 0)
+	for _, sshKey := range sortSSHKeysByName(sshKeys) {
+		if _, ok := declared[sshKey.Name]; ok {
+			continue
+		}
+		if _, ok := managed[sshKey.Name]; ok {
+			continue
+		}
+		switch {
+		case len(usage[sshKey.Name]) > 0:
+			undeclared = append(undeclared, sshKey.Name)
+			skipped = append(skipped, fmt.Sprintf("%s, used by %s", sshKey.Name, strings.Join(describeInstances(usage[sshKey.Name]), "; ")))
+		default:
+			deleted = append(deleted, sshKey.Name)
+		}
+	}
+
+	if len(deleted) > 0 {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("undeclared_keys"),
+			"Undeclared SSH Keys will be deleted",
+			"exclusive is set, so the following SSH Keys of the account, which are not in keys, will be deleted:\n\n  - "+strings.Join(deleted, "\n  - "),
+		)
+	}
+	if len(skipped) > 0 {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("undeclared_keys"),
+			"Undeclared SSH Keys in use will not be deleted",
+			"The following SSH Keys of the account are not in keys, but will not be deleted while instances use them:\n\n  - "+strings.Join(skipped, "\n  - "),
+		)
+	}
+
+	undeclaredKeys, diags := types.SetValueFrom(ctx, types.StringType, undeclared)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("undeclared_keys"), undeclaredKeys)...)
+}
+
+func (r *SshKeySetResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data SshKeySetResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.sync(ctx, &data, map[string]string{})...)
+
+	// Save data into Terraform state, along with the keys added before any error
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SshKeySetResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data SshKeySetResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	managed := make(map[string]string)
+	resp.Diagnostics.Append(data.Keys.ElementsAs(ctx, &managed, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	sshKeys, err := r.sshKeys.List(ctx)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostics("Failed to read SSH Keys", err, nil)...)
+		return
+	}
+
+	// Keys deleted out of band drop out of keys and changed public keys show up as drift, so
+	// that Terraform plans to add them again. The public key in state is kept when it only
+	// differs by whitespace or comment.
+	keys := make(map[string]string)
+	keyIDs := make(map[string]string)
+	undeclared := make([]string, 0)
+	for _, sshKey := range sortSSHKeysByName(sshKeys) {
+		publicKey, ok := managed[sshKey.Name]
+		if !ok {
+			undeclared = append(undeclared, sshKey.Name)
+			continue
+		}
+		if !publicKeysEqual(publicKey, sshKey.PublicKey) {
+			publicKey = sshKey.PublicKey
+		}
+		keys[sshKey.Name] = publicKey
+		keyIDs[sshKey.Name] = sshKey.Id
+	}
+
+	resp.Diagnostics.Append(data.set(ctx, keys, keyIDs, undeclared)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SshKeySetResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data SshKeySetResourceModel
+	var state SshKeySetResourceModel
+
+	// Read Terraform plan and prior state data into the models
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	managed := make(map[string]string)
+	resp.Diagnostics.Append(state.Keys.ElementsAs(ctx, &managed, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.sync(ctx, &data, managed)...)
+
+	// Save data into Terraform state, along with the changes made before any error
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *SshKeySetResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data SshKeySetResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	deleteTimeout, diags := data.Timeouts.Delete(ctx, defaultSSHKeyDeleteTimeout)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	keyIDs := make(map[string]string)
+	resp.Diagnostics.Append(data.KeyIDs.ElementsAs(ctx, &keyIDs, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Only the keys of the set are deleted, undeclared keys are left alone even with exclusive
+	names := make([]string, 0, len(keyIDs))
+	for name := range keyIDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		resp.Diagnostics.Append(deleteSSHKey(ctx, r.sshKeys, r.instances, keyIDs[name], name, deleteTimeout)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	tflog.Info(ctx, "Deleted SSH Key set", map[string]interface{}{"names": names})
+}
+
+// sync adds and replaces the keys of data, deletes the managed keys that were removed from it
+// and, with exclusive, the undeclared keys that are not in use. data is updated with what was
+// done, even when some of it failed.
+func (r *SshKeySetResource) sync(ctx context.Context, data *SshKeySetResourceModel, managed map[string]string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	desired := make(map[string]string)
+	diags.Append(data.Keys.ElementsAs(ctx, &desired, false)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	sshKeys, err := r.sshKeys.List(ctx)
+	if err != nil {
+		diags.Append(apiErrorDiagnostics("Failed to list SSH Keys", err, nil)...)
+		return diags
+	}
+	usage, err := instancesBySSHKeyName(ctx, r.instances)
+	if err != nil {
+		diags.Append(apiErrorDiagnostics("Failed to list instances", err, nil)...)
+		return diags
+	}
+	existing := make(map[string]lambdalabs.SshKey, len(sshKeys))
+	for _, sshKey := range sshKeys {
+		existing[sshKey.Name] = sshKey
+	}
+
+	keys := make(map[string]string)
+	keyIDs := make(map[string]string)
+
+	// deleteUnused deletes a key unless instances use it, since the API would refuse to.
+	deleteUnused := func(sshKey lambdalabs.SshKey, summary string) bool {
+		if blocking := usage[sshKey.Name]; len(blocking) > 0 {
+			diags.AddError(summary, fmt.Sprintf("SSH Key %q is used by the following instances:\n\n  - %s\n\nTerminate them first.",
+				sshKey.Name, strings.Join(describeInstances(blocking), "\n  - ")))
+			return false
+		}
+		if err := r.sshKeys.Delete(ctx, sshKey.Id); err != nil && !lambdalabs.IsNotFound(err) {
+			diags.Append(apiErrorDiagnostics(summary, err, nil)...)
+			return false
+		}
+		return true
+	}
+
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		publicKey := desired[name]
+		if sshKey, ok := existing[name]; ok {
+			if publicKeysEqual(sshKey.PublicKey, publicKey) {
+				keys[name] = publicKey
+				keyIDs[name] = sshKey.Id
+				continue
+			}
+			// The API cannot change a public key, so the key is deleted and added again
+			if !deleteUnused(sshKey, fmt.Sprintf("Failed to replace SSH Key %q", name)) {
+				if previous, ok := managed[name]; ok {
+					keys[name] = previous
+					keyIDs[name] = sshKey.Id
+				}
+				continue
+			}
+		}
+		added, err := r.sshKeys.Add(ctx, lambdalabs.AddSSHKeyJSONRequestBody{Name: name, PublicKey: &publicKey})
+		if err != nil {
+			diags.Append(apiErrorDiagnostics(fmt.Sprintf("Failed to add SSH Key %q", name), err, nil)...)
+			continue
+		}
+		tflog.Info(ctx, "Added SSH Key", map[string]interface{}{"name": name, "id": added.Id})
+		keys[name] = publicKey
+		keyIDs[name] = added.Id
+	}
+
+	// The keys planned to stay undeclared are never deleted, and keys added to the account
+	// after the plan are only deleted with exclusive, as they would have been planned to.
+	var planned map[string]bool
+	if !data.UndeclaredKeys.IsUnknown() && !data.UndeclaredKeys.IsNull() {
+		var names []string
+		diags.Append(data.UndeclaredKeys.ElementsAs(ctx, &names, false)...)
+		planned = make(map[string]bool, len(names))
+		for _, name := range names {
+			planned[name] = true
+		}
+	}
+	undeclared := make([]string, 0)
+	for _, sshKey := range sortSSHKeysByName(sshKeys) {
+		if _, ok := desired[sshKey.Name]; ok {
+			continue
+		}
+		if previous, ok := managed[sshKey.Name]; ok {
+			if !deleteUnused(sshKey, fmt.Sprintf("Failed to delete SSH Key %q", sshKey.Name)) {
+				keys[sshKey.Name] = previous
+				keyIDs[sshKey.Name] = sshKey.Id
+			}
+			continue
+		}
+		if planned[sshKey.Name] || !data.Exclusive.ValueBool() || (planned == nil && len(usage[sshKey.Name]) > 0) {
+			undeclared = append(undeclared, sshKey.Name)
+			continue
+		}
+		if !deleteUnused(sshKey, fmt.Sprintf("Failed to delete undeclared SSH Key %q", sshKey.Name)) {
+			undeclared = append(undeclared, sshKey.Name)
+			continue
+		}
+		tflog.Info(ctx, "Deleted undeclared SSH Key", map[string]interface{}{"name": sshKey.Name, "id": sshKey.Id})
+	}
+
+	// Keys that appear or go away between plan and apply show up on the next refresh
+	if planned != nil && !diags.HasError() {
+		undeclared = undeclared[:0]
+		for name := range planned {
+			undeclared = append(undeclared, name)
+		}
+	}
+
+	diags.Append(data.set(ctx, keys, keyIDs, undeclared)...)
+	return diags
+}
+
+// set sets the ID and the keys of the set.
+func (m *SshKeySetResourceModel) set(ctx context.Context, keys map[string]string, keyIDs map[string]string, undeclared []string) diag.Diagnostics {
+	var diags, d diag.Diagnostics
+
+	m.ID = types.StringValue(sshKeySetID)
+	m.Keys, d = types.MapValueFrom(ctx, types.StringType, keys)
+	diags.Append(d...)
+	m.KeyIDs, d = types.MapValueFrom(ctx, types.StringType, keyIDs)
+	diags.Append(d...)
+	m.UndeclaredKeys, d = types.SetValueFrom(ctx, types.StringType, undeclared)
+	diags.Append(d...)
+	return diags
+}
+
+// sameSSHKeys reports whether keys has the same names as managed, and the same public keys
+// regardless of whitespace and comments.
+func sameSSHKeys(keys types.Map, managed map[string]types.String) bool {
+	if keys.IsUnknown() || keys.IsNull() || len(keys.Elements()) != len(managed) {
+		return false
+	}
+	for name, value := range keys.Elements() {
+		publicKey, ok := value.(types.String)
+		previous, found := managed[name]
+		if !ok || !found || publicKey.IsUnknown() || !publicKeysEqual(publicKey.ValueString(), previous.ValueString()) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortSSHKeysByName returns a copy of sshKeys sorted by name.
+func sortSSHKeysByName(sshKeys []lambdalabs.SshKey) []lambdalabs.SshKey {
+	sorted := append([]lambdalabs.SshKey(nil), sshKeys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
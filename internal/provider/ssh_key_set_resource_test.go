@@ -0,0 +1,503 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"terraform-provider-lambdalabs/internal/fakelambda"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+// testAccThirdPublicKey is a valid public key that differs from testAccPublicKey and testAccOtherPublicKey.
+const testAccThirdPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIPnC44uo7OUyVK7yKRnzsEbVJbYgv8SZqVYxS/LKxgiu ci"
+
+func testAccSSHKeySetResourceConfig(exclusive bool, keys map[string]string) string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "    %q = %q\n", name, keys[name])
+	}
+	return testAccProviderConfig + fmt.Sprintf(`
+resource "lambdalabs_ssh_key_set" "test" {
+  exclusive = %t
+
+  keys = {
+%s  }
+}
+`, exclusive, b.String())
+}
+
+// testAccCheckSSHKeys checks that the fake API has exactly the given public keys by name.
+func testAccCheckSSHKeys(server *fakelambda.Server, expected map[string]string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		sshKeys := server.SSHKeys()
+		if len(sshKeys) != len(expected) {
+			return fmt.Errorf("expected %d SSH keys, got %v", len(expected), sshKeys)
+		}
+		for _, sshKey := range sshKeys {
+			publicKey, ok := expected[sshKey.Name]
+			if !ok {
+				return fmt.Errorf("unexpected SSH key %s", sshKey.Name)
+			}
+			if !publicKeysEqual(sshKey.PublicKey, publicKey) {
+				return fmt.Errorf("expected SSH key %s to have public key %q, got %q", sshKey.Name, publicKey, sshKey.PublicKey)
+			}
+		}
+		return nil
+	}
+}
+
+func TestAccSSHKeySetResource(t *testing.T) {
+	server := testAccFakeServer(t)
+	laptop := server.AddSSHKey("laptop", testAccPublicKey)
+	server.AddSSHKey("stale", testAccOtherPublicKey)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckSSHKeys(server, map[string]string{"stale": testAccOtherPublicKey}),
+		Steps: []resource.TestStep{
+			// An existing key with the same public key is adopted, a missing key is added and
+			// undeclared keys are left alone
+			{
+				Config: testAccSSHKeySetResourceConfig(false, map[string]string{
+					"laptop": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGcQYOS6lHnQ5EBcLvAdmuM7UTn0yuupDWR+8Ng0jkTZ laptop",
+					"ci":     testAccThirdPublicKey,
+				}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key_set.test", tfjsonpath.New("id"), knownvalue.StringExact(sshKeySetID)),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key_set.test", tfjsonpath.New("exclusive"), knownvalue.Bool(false)),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key_set.test", tfjsonpath.New("key_ids"), knownvalue.MapExact(map[string]knownvalue.Check{
+						"laptop": knownvalue.StringExact(laptop.Id),
+						"ci":     knownvalue.NotNull(),
+					})),
+					statecheck.ExpectKnownValue("lambdalabs_ssh_key_set.test", tfjsonpath.New("undeclared_keys"), knownvalue.SetExact([]knownvalue.Check{
+						knownvalue.StringExact("stale"),
+					})),
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccCheckSSHKeys(server, map[string]string{
+						"laptop": testAccPublicKey,
+						"ci":     testAccThirdPublicKey,
+						"stale":  testAccOtherPublicKey,
+					}),
+					func(s *terraform.State) error {
+						if calls := server.Calls(fakelambda.OperationAddSSHKey); calls != 1 {
+							return fmt.Errorf("expected only the missing key to be added, got %d calls", calls)
+						}
+						return nil
+					},
+				),
+			},
+			// A changed public key replaces the key
+			{
+				Config: testAccSSHKeySetResourceConfig(false, map[string]string{
+					"laptop": testAccPublicKey,
+					"ci":     testAccOtherPublicKey,
+				}),
+				Check: testAccCheckSSHKeys(server, map[string]string{
+					"laptop": testAccPublicKey,
+					"ci":     testAccOtherPublicKey,
+					"stale":  testAccOtherPublicKey,
+				}),
+			},
+			// Exclusive plans to delete undeclared keys, and deletes them
+			{
+				Config: testAccSSHKeySetResourceConfig(true, map[string]string{
+					"laptop": testAccPublicKey,
+					"ci":     testAccOtherPublicKey,
+				}),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("lambdalabs_ssh_key_set.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_ssh_key_set.test", "undeclared_keys.#", "0"),
+					testAccCheckSSHKeys(server, map[string]string{
+						"laptop": testAccPublicKey,
+						"ci":     testAccOtherPublicKey,
+					}),
+				),
+			},
+			// Keys removed from keys are deleted
+			{
+				Config: testAccSSHKeySetResourceConfig(true, map[string]string{
+					"laptop": testAccPublicKey,
+				}),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("lambdalabs_ssh_key_set.test", "key_ids.ci"),
+					testAccCheckSSHKeys(server, map[string]string{"laptop": testAccPublicKey}),
+				),
+			},
+			// A key deleted out of band is planned to be added again
+			{
+				PreConfig: func() {
+					server.DeleteSSHKey(testAccSSHKeyID(t, server, "laptop"))
+				},
+				RefreshState: true,
+				RefreshPlanChecks: resource.RefreshPlanChecks{
+					PostRefresh: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("lambdalabs_ssh_key_set.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				ExpectNonEmptyPlan: true,
+			},
+			// Destroying the set only deletes its keys, which leaves the key added in between
+			{
+				PreConfig: func() {
+					server.AddSSHKey("stale", testAccOtherPublicKey)
+				},
+				Config: testAccSSHKeySetResourceConfig(false, map[string]string{
+					"laptop": testAccPublicKey,
+				}),
+				Check: testAccCheckSSHKeys(server, map[string]string{
+					"laptop": testAccPublicKey,
+					"stale":  testAccOtherPublicKey,
+				}),
+			},
+		},
+	})
+}
+
+func TestAccSSHKeySetResourceExclusiveSkipsKeysInUse(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddSSHKey("in-use", testAccOtherPublicKey)
+	server.AddSSHKey("unused", testAccThirdPublicKey)
+	testAccLaunchInstance(t, server, "blocking-instance", "in-use")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testAccCheckSSHKeys(server, map[string]string{"in-use": testAccOtherPublicKey}),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHKeySetResourceConfig(true, map[string]string{
+					"laptop": testAccPublicKey,
+				}),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_ssh_key_set.test", "undeclared_keys.#", "1"),
+					resource.TestCheckTypeSetElemAttr("lambdalabs_ssh_key_set.test", "undeclared_keys.*", "in-use"),
+					testAccCheckSSHKeys(server, map[string]string{
+						"laptop": testAccPublicKey,
+						"in-use": testAccOtherPublicKey,
+					}),
+				),
+			},
+			// The key in use is not planned to be deleted again
+			{
+				Config: testAccSSHKeySetResourceConfig(true, map[string]string{
+					"laptop": testAccPublicKey,
+				}),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccSSHKeySetResourceReplaceKeyInUse(t *testing.T) {
+	server := testAccFakeServer(t)
+	var instanceID string
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHKeySetResourceConfig(false, map[string]string{
+					"laptop": testAccPublicKey,
+				}),
+			},
+			// The key cannot be replaced while an instance uses it, and keeps its public key
+			{
+				PreConfig: func() {
+					instanceID, _ = testAccLaunchInstance(t, server, "blocking-instance", "laptop")
+				},
+				Config: testAccSSHKeySetResourceConfig(false, map[string]string{
+					"laptop": testAccOtherPublicKey,
+				}),
+				ExpectError: regexp.MustCompile(`(?s)Failed to replace SSH Key "laptop".*blocking-instance`),
+			},
+			// The replacement is still planned, as the configuration asks for the other key
+			{
+				RefreshState:       true,
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("lambdalabs_ssh_key_set.test", "keys.laptop", testAccPublicKey),
+					testAccCheckSSHKeys(server, map[string]string{"laptop": testAccPublicKey}),
+				),
+			},
+			// Once the instance is terminated, the key is replaced
+			{
+				PreConfig: func() {
+					server.SetInstanceStatus(instanceID, lambdalabs.InstanceStatusTerminated)
+				},
+				Config: testAccSSHKeySetResourceConfig(false, map[string]string{
+					"laptop": testAccOtherPublicKey,
+				}),
+				Check: testAccCheckSSHKeys(server, map[string]string{"laptop": testAccOtherPublicKey}),
+			},
+		},
+	})
+}
+
+func TestAccSSHKeySetResourceInvalidPublicKey(t *testing.T) {
+	server := testAccFakeServer(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSSHKeySetResourceConfig(false, map[string]string{
+					"laptop": testAccPublicKey,
+					"broken": "ssh-ed25519 not-a-key",
+				}),
+				ExpectError: regexp.MustCompile(`Invalid public key`),
+			},
+		},
+	})
+	if calls := server.Calls(fakelambda.OperationAddSSHKey); calls != 0 {
+		t.Errorf("expected the invalid key to be rejected during plan, got %d API calls", calls)
+	}
+}
+
+func TestSSHKeySetResourceAdoptReplaceAndRemove(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	laptop := server.AddSSHKey("laptop", testAccPublicKey)
+	server.AddSSHKey("stale", testAccOtherPublicKey)
+	p := newTestProvider(t, server)
+
+	// An existing key with the same public key, regardless of comment, is adopted and a
+	// missing key is added
+	config := map[string]interface{}{
+		"keys": map[string]string{
+			"laptop": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGcQYOS6lHnQ5EBcLvAdmuM7UTn0yuupDWR+8Ng0jkTZ laptop",
+			"ci":     testAccThirdPublicKey,
+		},
+	}
+	state, diags := p.apply("lambdalabs_ssh_key_set", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key_set"), nil), config)
+	requireNoErrors(t, "Create", diags)
+	if calls := server.Calls(fakelambda.OperationAddSSHKey); calls != 1 {
+		t.Errorf("expected only the missing key to be added, got %d calls", calls)
+	}
+	if id := testString(t, state, "key_ids", "laptop"); id != laptop.Id {
+		t.Errorf("expected the existing key %s to be adopted, got %s", laptop.Id, id)
+	}
+	if undeclared := testStrings(t, state, "undeclared_keys"); !reflect.DeepEqual(undeclared, []string{"stale"}) {
+		t.Errorf("expected undeclared_keys [stale], got %v", undeclared)
+	}
+	requireSSHKeys(t, server, map[string]string{
+		"laptop": testAccPublicKey,
+		"ci":     testAccThirdPublicKey,
+		"stale":  testAccOtherPublicKey,
+	})
+	p.requireEmptyPlan("lambdalabs_ssh_key_set", state, config)
+
+	// A changed public key replaces the key
+	ciID := testString(t, state, "key_ids", "ci")
+	config = map[string]interface{}{
+		"keys": map[string]string{"laptop": testAccPublicKey, "ci": testAccOtherPublicKey},
+	}
+	state, diags = p.apply("lambdalabs_ssh_key_set", state, config)
+	requireNoErrors(t, "Update", diags)
+	if id := testString(t, state, "key_ids", "ci"); id == ciID {
+		t.Errorf("expected key ci to be replaced, got the same ID %s", id)
+	}
+	requireSSHKeys(t, server, map[string]string{
+		"laptop": testAccPublicKey,
+		"ci":     testAccOtherPublicKey,
+		"stale":  testAccOtherPublicKey,
+	})
+	p.requireEmptyPlan("lambdalabs_ssh_key_set", state, config)
+
+	// Keys removed from keys are deleted, undeclared keys are not
+	config = map[string]interface{}{
+		"keys": map[string]string{"laptop": testAccPublicKey},
+	}
+	state, diags = p.apply("lambdalabs_ssh_key_set", state, config)
+	requireNoErrors(t, "Update", diags)
+	requireSSHKeys(t, server, map[string]string{
+		"laptop": testAccPublicKey,
+		"stale":  testAccOtherPublicKey,
+	})
+	p.requireEmptyPlan("lambdalabs_ssh_key_set", state, config)
+
+	// Destroying the set only deletes its keys
+	_, diags = p.apply("lambdalabs_ssh_key_set", state, nil)
+	requireNoErrors(t, "Delete", diags)
+	requireSSHKeys(t, server, map[string]string{"stale": testAccOtherPublicKey})
+}
+
+func TestSSHKeySetResourceExclusive(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.AddSSHKey("in-use", testAccOtherPublicKey)
+	server.AddSSHKey("unused", testAccThirdPublicKey)
+	testAccLaunchInstance(t, server, "blocking-instance", "in-use")
+	p := newTestProvider(t, server)
+	config := map[string]interface{}{
+		"exclusive": true,
+		"keys":      map[string]string{"laptop": testAccPublicKey},
+	}
+
+	// The plan shows which undeclared keys are deleted, and which are kept while in use
+	planned, _ := p.plan("lambdalabs_ssh_key_set", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key_set"), nil), config)
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	requireWarning(t, planned.Diagnostics, `(?s)Undeclared SSH Keys will be deleted.*unused`)
+	requireWarning(t, planned.Diagnostics, `(?s)Undeclared SSH Keys in use will not be deleted.*in-use, used by .*blocking-instance`)
+
+	state, diags := p.apply("lambdalabs_ssh_key_set", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key_set"), nil), config)
+	requireNoErrors(t, "Create", diags)
+	if undeclared := testStrings(t, state, "undeclared_keys"); !reflect.DeepEqual(undeclared, []string{"in-use"}) {
+		t.Errorf("expected undeclared_keys [in-use], got %v", undeclared)
+	}
+	requireSSHKeys(t, server, map[string]string{
+		"laptop": testAccPublicKey,
+		"in-use": testAccOtherPublicKey,
+	})
+
+	// The key in use is not planned to be deleted again
+	p.requireEmptyPlan("lambdalabs_ssh_key_set", state, config)
+}
+
+func TestSSHKeySetResourceUndeclaredKeysDrift(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	p := newTestProvider(t, server)
+	config := map[string]interface{}{
+		"keys": map[string]string{"laptop": testAccPublicKey, "ci": testAccThirdPublicKey},
+	}
+	state, diags := p.apply("lambdalabs_ssh_key_set", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key_set"), nil), config)
+	requireNoErrors(t, "Create", diags)
+
+	// Keys added out of band show up in undeclared_keys, and keys deleted out of band drop
+	// out of keys, so that they are planned to be added again
+	server.AddSSHKey("stale", testAccOtherPublicKey)
+	server.DeleteSSHKey(testString(t, state, "key_ids", "ci"))
+	state, diags = p.read("lambdalabs_ssh_key_set", state)
+	requireNoErrors(t, "Read", diags)
+	if undeclared := testStrings(t, state, "undeclared_keys"); !reflect.DeepEqual(undeclared, []string{"stale"}) {
+		t.Errorf("expected undeclared_keys [stale], got %v", undeclared)
+	}
+	if keys := testAttribute(t, state, "key_ids"); !keys.Equal(testValue(t, keys.Type(), map[string]string{"laptop": testString(t, state, "key_ids", "laptop")})) {
+		t.Errorf("expected only key laptop to be left, got %s", keys)
+	}
+
+	// With exclusive, the key added out of band is deleted and the deleted one added again
+	config["exclusive"] = true
+	state, diags = p.apply("lambdalabs_ssh_key_set", state, config)
+	requireNoErrors(t, "Update", diags)
+	requireSSHKeys(t, server, map[string]string{
+		"laptop": testAccPublicKey,
+		"ci":     testAccThirdPublicKey,
+	})
+	p.requireEmptyPlan("lambdalabs_ssh_key_set", state, config)
+}
+
+func TestSSHKeySetResourcePlanComputedValues(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	p := newTestProvider(t, server)
+	config := map[string]interface{}{
+		"keys": map[string]string{"laptop": testAccPublicKey},
+	}
+	state, diags := p.apply("lambdalabs_ssh_key_set", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key_set"), nil), config)
+	requireNoErrors(t, "Create", diags)
+	server.AddSSHKey("stale", testAccOtherPublicKey)
+
+	// Without exclusive, key_ids and undeclared_keys are kept from state while the keys are
+	// the same, even if the account changed, and comment changes do not count
+	config["keys"] = map[string]string{"laptop": strings.TrimSuffix(testAccPublicKey, "acceptance-test") + "laptop"}
+	planned, _ := p.plan("lambdalabs_ssh_key_set", state, config)
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	plannedState := p.value(planned.PlannedState, p.resourceType("lambdalabs_ssh_key_set"))
+	for _, name := range []string{"key_ids", "undeclared_keys"} {
+		if value, prior := testAttribute(t, plannedState, name), testAttribute(t, state, name); !value.Equal(prior) {
+			t.Errorf("expected %s to be kept as %s, got %s", name, prior, value)
+		}
+	}
+
+	// Both are unknown once a key is added
+	config["keys"] = map[string]string{"laptop": testAccPublicKey, "ci": testAccThirdPublicKey}
+	planned, _ = p.plan("lambdalabs_ssh_key_set", state, config)
+	requireNoErrors(t, "Plan", planned.Diagnostics)
+	plannedState = p.value(planned.PlannedState, p.resourceType("lambdalabs_ssh_key_set"))
+	for _, name := range []string{"key_ids", "undeclared_keys"} {
+		if value := testAttribute(t, plannedState, name); value.IsKnown() {
+			t.Errorf("expected %s to be unknown, got %s", name, value)
+		}
+	}
+	state, diags = p.apply("lambdalabs_ssh_key_set", state, config)
+	requireNoErrors(t, "Update", diags)
+	if undeclared := testStrings(t, state, "undeclared_keys"); !reflect.DeepEqual(undeclared, []string{"stale"}) {
+		t.Errorf("expected undeclared_keys [stale], got %v", undeclared)
+	}
+	p.requireEmptyPlan("lambdalabs_ssh_key_set", state, config)
+}
+
+func TestSSHKeySetResourceDeleteKeysInUse(t *testing.T) {
+	server := fakelambda.NewServer()
+	t.Cleanup(server.Close)
+	server.TerminatePolls = 4
+	testAccShortenSSHKeyDeletePollInterval(t)
+	p := newTestProvider(t, server)
+	config := map[string]interface{}{
+		"keys":     map[string]string{"laptop": testAccPublicKey, "ci": testAccThirdPublicKey},
+		"timeouts": map[string]interface{}{"delete": "200ms"},
+	}
+	state, diags := p.apply("lambdalabs_ssh_key_set", tftypes.NewValue(p.resourceType("lambdalabs_ssh_key_set"), nil), config)
+	requireNoErrors(t, "Create", diags)
+
+	// Deleting a key used by an active instance is retried until the delete timeout
+	activeID, _ := testAccLaunchInstance(t, server, "blocking-instance", "laptop")
+	_, diags = p.apply("lambdalabs_ssh_key_set", state, nil)
+	requireError(t, diags, `(?s)SSH Key in use.*blocking-instance`)
+	if calls := server.Calls(fakelambda.OperationDeleteSSHKey); calls < 3 {
+		t.Errorf("expected deleting the key in use to be retried, got %d calls", calls)
+	}
+
+	requireSSHKeys(t, server, map[string]string{"laptop": testAccPublicKey})
+
+	// Deleting a key used by a terminating instance succeeds once it is terminated, and the
+	// key deleted by the failed attempt is not an error. The terminating instance is launched
+	// first, so that a delete request of the failed attempt still in flight cannot succeed.
+	terminatingID, instances := testAccLaunchInstance(t, server, "terminating-instance", "laptop")
+	if _, err := instances.Terminate(context.Background(), []string{terminatingID}); err != nil {
+		t.Fatal(err)
+	}
+	server.SetInstanceStatus(activeID, lambdalabs.InstanceStatusTerminated)
+	calls := server.Calls(fakelambda.OperationDeleteSSHKey)
+	_, diags = p.apply("lambdalabs_ssh_key_set", state, nil)
+	requireNoErrors(t, "Delete", diags)
+	requireSSHKeys(t, server, map[string]string{})
+	if retries := server.Calls(fakelambda.OperationDeleteSSHKey) - calls; retries < 3 {
+		t.Errorf("expected deleting the key used by a terminating instance to be retried, got %d calls", retries)
+	}
+}
+
+// requireSSHKeys fails the test unless the fake API has exactly the given public keys by name.
+func requireSSHKeys(t *testing.T, server *fakelambda.Server, expected map[string]string) {
+	t.Helper()
+	if err := testAccCheckSSHKeys(server, expected)(nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -22,7 +22,7 @@ func NewSSHKeysDataSource() datasource.DataSource {
 
 // sshkeysDataSource is the data source implementation.
 type sshkeysDataSource struct {
-	client *lambdalabs.ClientWithResponses
+	sshKeys lambdalabs.SSHKeysService
 }
 
 // sshkeysDataSourceModel maps the data source schema data.
@@ -71,55 +71,36 @@ func (d *sshkeysDataSource) Schema(_ context.Context, _ datasource.SchemaRequest
 	}
 }
 
-// Configure adds the provider configured client to the data source.
+// Configure adds the provider configured services to the data source.
 func (d *sshkeysDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
 
 	if req.ProviderData == nil {
 		return
 	}
 
-	client, ok := req.ProviderData.(*lambdalabs.ClientWithResponses)
+	services, ok := req.ProviderData.(*lambdalabs.Services)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *lambdalabs.Client, got %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *lambdalabs.Services, got %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
 	}
 
-	d.client = client
+	d.sshKeys = services.SSHKeys
 }
 
 // Read refreshes the Terraform state with the latest data.
 func (d *sshkeysDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
 	var state sshkeysDataSourceModel
 
-	r, err := d.client.ListSSHKeysWithResponse(ctx)
+	sshkeys, err := d.sshKeys.List(ctx)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs SSHKeys",
-			err.Error(),
-		)
-		return
-	}
-	switch r.StatusCode() {
-	case 403, 401:
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs SSHKeys",
-			"Invalid API Key",
-		)
-	}
-	if r.JSON200 == nil {
-		resp.Diagnostics.AddError(
-			"Unable to Read Lambda Labs SSHKeys",
-			"Response body is empty",
-		)
+		resp.Diagnostics.Append(apiErrorDiagnostics("Unable to Read Lambda Labs SSHKeys", err, nil)...)
 		return
 	}
 
-	sshkeys := r.JSON200.Data
-
 	// Map response body to model
 	for _, sshkey := range sshkeys {
 
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"terraform-provider-lambdalabs/internal/fakelambda"
+	"terraform-provider-lambdalabs/pgk/lambdalabs"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+)
+
+func TestAccSSHKeysDataSource(t *testing.T) {
+	server := testAccFakeServer(t)
+	laptop := server.AddSSHKey("laptop", testAccPublicKey)
+	server.AddSSHKey("workstation", testAccPublicKey)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig + `data "lambdalabs_ssh_keys" "all" {}`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.lambdalabs_ssh_keys.all", "sshkeys.#", "2"),
+					resource.TestCheckResourceAttr("data.lambdalabs_ssh_keys.all", "sshkeys.0.id", laptop.Id),
+					resource.TestCheckResourceAttr("data.lambdalabs_ssh_keys.all", "sshkeys.0.name", "laptop"),
+					resource.TestCheckResourceAttr("data.lambdalabs_ssh_keys.all", "sshkeys.1.name", "workstation"),
+				),
+			},
+			// Transient server errors are retried
+			{
+				PreConfig: func() {
+					server.InjectErrorStatus(fakelambda.OperationListSSHKeys, http.StatusServiceUnavailable, lambdalabs.ErrorCodeUnknown, 2)
+				},
+				Config: testAccProviderConfig + `data "lambdalabs_ssh_keys" "all" {}`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.lambdalabs_ssh_keys.all", "sshkeys.#", "2"),
+					func(*terraform.State) error {
+						if calls := server.Calls(fakelambda.OperationListSSHKeys); calls < 3 {
+							return fmt.Errorf("expected the failed requests to be retried, got %d calls", calls)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
@@ -7,9 +7,15 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/defaults"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"time"
 )
 
 var _ validator.List = &ListMaxLength{}
+var _ validator.List = &ListMinLength{}
+var _ validator.String = &DurationValidator{}
+var _ validator.String = &PublicKeyValidator{}
+var _ validator.Int64 = &Int64AtLeast{}
+var _ validator.Float64 = &Float64AtLeast{}
 var _ defaults.Int64 = &Int64Default{}
 var _ defaults.List = &ListDefaultEmpty{}
 
@@ -35,6 +41,134 @@ func (m ListMaxLength) ValidateList(ctx context.Context, request validator.ListR
 	}
 }
 
+// ListMinLength is a schema validator for the minimum length of types.List.
+type ListMinLength struct {
+	min int
+}
+
+func (m ListMinLength) Description(ctx context.Context) string {
+	return "Min length validator"
+}
+
+func (m ListMinLength) MarkdownDescription(ctx context.Context) string {
+	return "Min length validator"
+}
+
+func (m ListMinLength) ValidateList(ctx context.Context, request validator.ListRequest, response *validator.ListResponse) {
+	if request.ConfigValue.IsNull() || request.ConfigValue.IsUnknown() {
+		return
+	}
+	if len(request.ConfigValue.Elements()) < m.min {
+		response.Diagnostics.AddAttributeError(request.Path, "List too short", fmt.Sprintf("List is too short, min length is %d", m.min))
+	}
+}
+
+// Int64AtLeast is a schema validator for the minimum value of types.Int64.
+type Int64AtLeast struct {
+	min int64
+}
+
+func (m Int64AtLeast) Description(ctx context.Context) string {
+	return fmt.Sprintf("Value must be at least %d", m.min)
+}
+
+func (m Int64AtLeast) MarkdownDescription(ctx context.Context) string {
+	return fmt.Sprintf("Value must be at least %d", m.min)
+}
+
+func (m Int64AtLeast) ValidateInt64(ctx context.Context, request validator.Int64Request, response *validator.Int64Response) {
+	if request.ConfigValue.IsNull() || request.ConfigValue.IsUnknown() {
+		return
+	}
+	if request.ConfigValue.ValueInt64() < m.min {
+		response.Diagnostics.AddAttributeError(
+			request.Path,
+			"Value too small",
+			fmt.Sprintf("Value must be at least %d, got %d", m.min, request.ConfigValue.ValueInt64()),
+		)
+	}
+}
+
+// Float64AtLeast is a schema validator for the minimum value of types.Float64.
+type Float64AtLeast struct {
+	min float64
+}
+
+func (m Float64AtLeast) Description(ctx context.Context) string {
+	return fmt.Sprintf("Value must be at least %g", m.min)
+}
+
+func (m Float64AtLeast) MarkdownDescription(ctx context.Context) string {
+	return fmt.Sprintf("Value must be at least %g", m.min)
+}
+
+func (m Float64AtLeast) ValidateFloat64(ctx context.Context, request validator.Float64Request, response *validator.Float64Response) {
+	if request.ConfigValue.IsNull() || request.ConfigValue.IsUnknown() {
+		return
+	}
+	if request.ConfigValue.ValueFloat64() < m.min {
+		response.Diagnostics.AddAttributeError(
+			request.Path,
+			"Value too small",
+			fmt.Sprintf("Value must be at least %g, got %g", m.min, request.ConfigValue.ValueFloat64()),
+		)
+	}
+}
+
+// DurationValidator is a schema validator for strings holding a positive duration, such as "20m".
+type DurationValidator struct{}
+
+func (v DurationValidator) Description(ctx context.Context) string {
+	return "Duration validator"
+}
+
+func (v DurationValidator) MarkdownDescription(ctx context.Context) string {
+	return "Duration validator"
+}
+
+func (v DurationValidator) ValidateString(ctx context.Context, request validator.StringRequest, response *validator.StringResponse) {
+	if request.ConfigValue.IsNull() || request.ConfigValue.IsUnknown() {
+		return
+	}
+	duration, err := time.ParseDuration(request.ConfigValue.ValueString())
+	if err != nil {
+		response.Diagnostics.AddAttributeError(
+			request.Path,
+			"Invalid duration",
+			fmt.Sprintf("%q is not a valid duration, use a value such as \"30s\", \"20m\" or \"6h\": %s", request.ConfigValue.ValueString(), err),
+		)
+		return
+	}
+	if duration <= 0 {
+		response.Diagnostics.AddAttributeError(request.Path, "Invalid duration", "Duration must be greater than zero")
+	}
+}
+
+// PublicKeyValidator is a schema validator for strings holding a single public key in
+// authorized_keys format.
+type PublicKeyValidator struct{}
+
+func (v PublicKeyValidator) Description(ctx context.Context) string {
+	return "Public key validator"
+}
+
+func (v PublicKeyValidator) MarkdownDescription(ctx context.Context) string {
+	return "Public key validator"
+}
+
+func (v PublicKeyValidator) ValidateString(ctx context.Context, request validator.StringRequest, response *validator.StringResponse) {
+	if request.ConfigValue.IsNull() || request.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := parsePublicKey(request.ConfigValue.ValueString()); err != nil {
+		response.Diagnostics.AddAttributeError(
+			request.Path,
+			"Invalid public key",
+			fmt.Sprintf("Public key must be in authorized_keys format, such as the contents of ~/.ssh/id_ed25519.pub: %s", err),
+		)
+	}
+}
+
 // Int64Default is a schema default value for types.Int64 attributes.
 type Int64Default struct {
 	defaultValue int64
@@ -0,0 +1,207 @@
+package lambdalabs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long list responses are reused by default. It is short enough to only
+// share responses between operations of the same plan or apply.
+const DefaultCacheTTL = 5 * time.Second
+
+// cachedCollections are the list endpoints whose responses are coalesced and cached, along with
+// the path prefixes of the mutating requests that invalidate them.
+var cachedCollections = map[string][]string{
+	"/instances":      {"/instance-operations/"},
+	"/instance-types": {"/instance-operations/"},
+	"/file-systems":   {"/instance-operations/"},
+	"/ssh-keys":       {"/ssh-keys"},
+}
+
+type cacheBypassKey struct{}
+
+// WithoutCache returns a context for requests that must not be answered from the cache, such as
+// polling for a status change. Their responses still refresh the cache.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// WithCache coalesces concurrent requests to the list endpoints (instances, instance types,
+// file systems and SSH keys) into a single API call, and reuses successful responses for ttl.
+// Any mutating request on a collection invalidates its cached response. A ttl of zero or less
+// disables the cache.
+//
+// It wraps the HttpRequestDoer configured so far, so it must come after WithHTTPClient and
+// should come after WithRetry, so that cache hits are not rate limited.
+func WithCache(ttl time.Duration) ClientOption {
+	return func(c *Client) error {
+		if ttl <= 0 {
+			return nil
+		}
+		c.Client = NewCachingDoer(c.Client, ttl)
+		return nil
+	}
+}
+
+// NewCachingDoer wraps doer, or a default http.Client if doer is nil, to coalesce and cache
+// requests as described by WithCache.
+func NewCachingDoer(doer HttpRequestDoer, ttl time.Duration) HttpRequestDoer {
+	if doer == nil {
+		doer = &http.Client{}
+	}
+	return &cachingDoer{
+		doer:    doer,
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+type cachingDoer struct {
+	doer HttpRequestDoer
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is a response that is either being fetched, until done is closed, or cached.
+type cacheEntry struct {
+	collection string
+	done       chan struct{}
+	response   *cachedResponse
+	err        error
+	expires    time.Time
+}
+
+type cachedResponse struct {
+	status     string
+	statusCode int
+	proto      string
+	header     http.Header
+	body       []byte
+}
+
+func (r *cachedResponse) toHTTP(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        r.status,
+		StatusCode:    r.statusCode,
+		Proto:         r.proto,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+func (d *cachingDoer) Do(req *http.Request) (*http.Response, error) {
+	collection, ok := cachedCollection(req)
+	if !ok {
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			return d.doer.Do(req)
+		}
+		// Invalidate before, so that reads racing the mutation are not served from the cache,
+		// and after, so that nothing read during the mutation stays cached.
+		d.invalidate(req.URL.Path)
+		defer d.invalidate(req.URL.Path)
+		return d.doer.Do(req)
+	}
+
+	key := req.URL.String()
+	bypass, _ := req.Context().Value(cacheBypassKey{}).(bool)
+
+	d.mu.Lock()
+	if entry, ok := d.entries[key]; ok && !bypass {
+		select {
+		case <-entry.done:
+			if entry.err == nil && time.Now().Before(entry.expires) {
+				d.mu.Unlock()
+				return entry.response.toHTTP(req), nil
+			}
+		default:
+			d.mu.Unlock()
+			return d.join(req, entry)
+		}
+	}
+	entry := &cacheEntry{collection: collection, done: make(chan struct{})}
+	d.entries[key] = entry
+	d.mu.Unlock()
+
+	resp, err := d.doer.Do(req)
+	if err == nil {
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err == nil {
+			entry.response = &cachedResponse{
+				status:     resp.Status,
+				statusCode: resp.StatusCode,
+				proto:      resp.Proto,
+				header:     resp.Header,
+				body:       body,
+			}
+		}
+	}
+	entry.err = err
+
+	d.mu.Lock()
+	entry.expires = time.Now().Add(d.ttl)
+	// Only successful responses are kept, failures are only shared with concurrent requests
+	if d.entries[key] == entry && (err != nil || entry.response.statusCode != http.StatusOK) {
+		delete(d.entries, key)
+	}
+	close(entry.done)
+	d.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return entry.response.toHTTP(req), nil
+}
+
+// join waits for a request to the same endpoint that is already in flight, and shares its
+// response.
+func (d *cachingDoer) join(req *http.Request, entry *cacheEntry) (*http.Response, error) {
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-entry.done:
+	}
+	if entry.err != nil {
+		// The request we joined may have failed because its own context was canceled
+		return d.doer.Do(req)
+	}
+	return entry.response.toHTTP(req), nil
+}
+
+// invalidate drops the cached responses of every collection that a mutating request to path
+// may change.
+func (d *cachingDoer) invalidate(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, entry := range d.entries {
+		for _, prefix := range cachedCollections[entry.collection] {
+			if strings.Contains(path, prefix) {
+				delete(d.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// cachedCollection returns the list endpoint requested by req, if it is one that is cached.
+func cachedCollection(req *http.Request) (string, bool) {
+	if req.Method != http.MethodGet {
+		return "", false
+	}
+	for collection := range cachedCollections {
+		if strings.HasSuffix(req.URL.Path, collection) {
+			return collection, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,124 @@
+package lambdalabs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCacheClient(t *testing.T, ttl time.Duration, handler http.HandlerFunc) *ClientWithResponses {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewAuthenticatedClient(server.URL, "secret", WithCache(ttl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// countingHandler counts list requests, and answers them after delay.
+func countingHandler(calls *int32, delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(calls, 1)
+		}
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/instances", "/ssh-keys":
+			_, _ = io.WriteString(w, `{"data": []}`)
+		case "/instance-operations/terminate":
+			_, _ = io.WriteString(w, `{"data": {"terminated_instances": []}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestCacheCoalescesConcurrentRequests(t *testing.T) {
+	var calls int32
+	client := newTestCacheClient(t, time.Minute, countingHandler(&calls, 20*time.Millisecond))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.ListInstancesWithResponse(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if response.JSON200 == nil {
+				t.Errorf("expected instances, got HTTP %d", response.StatusCode())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestCacheInvalidatedByMutation(t *testing.T) {
+	var calls int32
+	client := newTestCacheClient(t, time.Minute, countingHandler(&calls, 0))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListInstancesWithResponse(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.ListSSHKeysWithResponse(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+
+	if _, err := client.TerminateInstanceWithResponse(ctx, TerminateInstanceJSONRequestBody{InstanceIds: []string{"id"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListInstancesWithResponse(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListSSHKeysWithResponse(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("expected terminating to only invalidate the instance list, got %d calls", calls)
+	}
+}
+
+func TestCacheBypassAndExpiry(t *testing.T) {
+	var calls int32
+	client := newTestCacheClient(t, 20*time.Millisecond, countingHandler(&calls, 0))
+	ctx := context.Background()
+
+	if _, err := client.ListInstancesWithResponse(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListInstancesWithResponse(WithoutCache(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("expected the bypassing request to reach the API, got %d calls", calls)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := client.ListInstancesWithResponse(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("expected the expired response to be fetched again, got %d calls", calls)
+	}
+}
@@ -0,0 +1,132 @@
+package lambdalabs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Friendlier names for the error codes returned by the API.
+const (
+	ErrorCodeAccountInactive         = GlobalaccountInactive
+	ErrorCodeInvalidAPIKey           = GlobalinvalidApiKey
+	ErrorCodeInvalidParameters       = GlobalinvalidParameters
+	ErrorCodeObjectDoesNotExist      = GlobalobjectDoesNotExist
+	ErrorCodeQuotaExceeded           = GlobalquotaExceeded
+	ErrorCodeUnknown                 = Globalunknown
+	ErrorCodeFileSystemInWrongRegion = InstanceOperationslaunchfileSystemInWrongRegion
+	ErrorCodeFileSystemsNotSupported = InstanceOperationslaunchfileSystemsNotSupported
+	ErrorCodeInsufficientCapacity    = InstanceOperationslaunchinsufficientCapacity
+	ErrorCodeSSHKeyInUse             = SshKeyskeyInUse
+)
+
+// ErrNotFound is returned when looking up an object that does not exist, e.g. by name.
+var ErrNotFound = errors.New("lambda labs object not found")
+
+// APIError is an error response returned by the Lambda Labs API.
+type APIError struct {
+	// StatusCode HTTP status code of the response
+	StatusCode int
+
+	// Code Unique identifier for the type of error, empty if the body could not be decoded
+	Code ErrorCode
+
+	// Message Detailed description of the error
+	Message string
+
+	// Suggestion Suggestion of possible ways to fix the error, if any
+	Suggestion string
+
+	// FieldErrors Details about errors on a per-parameter basis, keyed by request parameter
+	FieldErrors map[string]Error
+
+	// Body Raw response body
+	Body []byte
+}
+
+// NewAPIError decodes the body of an error response. Bodies that are not an
+// errorResponseBody (e.g. from a proxy or load balancer) are kept as the message.
+func NewAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Body:       body,
+	}
+
+	var decoded ErrorResponseBody
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded.Error.Code == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(statusCode)
+		}
+		return apiErr
+	}
+
+	apiErr.Code = decoded.Error.Code
+	apiErr.Message = decoded.Error.Message
+	if decoded.Error.Suggestion != nil {
+		apiErr.Suggestion = *decoded.Error.Suggestion
+	}
+	if decoded.FieldErrors != nil {
+		apiErr.FieldErrors = *decoded.FieldErrors
+	}
+	return apiErr
+}
+
+// CheckResponse returns an *APIError for non-2xx responses, and nil otherwise.
+func CheckResponse(statusCode int, body []byte) error {
+	if statusCode >= 200 && statusCode < 300 {
+		return nil
+	}
+	return NewAPIError(statusCode, body)
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "lambda labs API error (HTTP %d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&b, ", %s", e.Code)
+	}
+	fmt.Fprintf(&b, "): %s", e.Message)
+	if e.Suggestion != "" {
+		fmt.Fprintf(&b, " %s", e.Suggestion)
+	}
+
+	fields := make([]string, 0, len(e.FieldErrors))
+	for field := range e.FieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fmt.Fprintf(&b, "; %s: %s", field, e.FieldErrors[field].Message)
+	}
+	return b.String()
+}
+
+// IsErrorCode reports whether err is an *APIError with one of the given codes.
+func IsErrorCode(err error, codes ...ErrorCode) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotFound reports whether err is ErrNotFound, or an *APIError for an object that does not exist.
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || apiErr.Code == ErrorCodeObjectDoesNotExist
+}
@@ -0,0 +1,71 @@
+package lambdalabs
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewAPIError(t *testing.T) {
+	body := []byte(`{
+		"error": {
+			"code": "global/invalid-parameters",
+			"message": "Invalid request.",
+			"suggestion": "Check the instance type."
+		},
+		"field_errors": {
+			"instance_type_name": {"code": "global/invalid-parameters", "message": "Unknown instance type."}
+		}
+	}`)
+
+	err := NewAPIError(400, body)
+	if err.Code != ErrorCodeInvalidParameters {
+		t.Errorf("expected code %q, got %q", ErrorCodeInvalidParameters, err.Code)
+	}
+	if err.Suggestion != "Check the instance type." {
+		t.Errorf("unexpected suggestion %q", err.Suggestion)
+	}
+	if err.FieldErrors["instance_type_name"].Message != "Unknown instance type." {
+		t.Errorf("unexpected field errors %v", err.FieldErrors)
+	}
+
+	expected := "lambda labs API error (HTTP 400, global/invalid-parameters): Invalid request. " +
+		"Check the instance type.; instance_type_name: Unknown instance type."
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestNewAPIErrorUndecodableBody(t *testing.T) {
+	err := NewAPIError(502, []byte("<html>Bad Gateway</html>\n"))
+	if err.Code != "" {
+		t.Errorf("expected no code, got %q", err.Code)
+	}
+	if err.Message != "<html>Bad Gateway</html>" {
+		t.Errorf("unexpected message %q", err.Message)
+	}
+
+	err = NewAPIError(503, nil)
+	if err.Message != "Service Unavailable" {
+		t.Errorf("unexpected message %q", err.Message)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse(200, nil); err != nil {
+		t.Errorf("expected no error for HTTP 200, got %s", err)
+	}
+
+	err := fmt.Errorf("launch failed: %w", CheckResponse(400, []byte(`{"error": {"code": "instance-operations/launch/insufficient-capacity", "message": "Not enough capacity."}}`)))
+	if !IsErrorCode(err, ErrorCodeQuotaExceeded, ErrorCodeInsufficientCapacity) {
+		t.Errorf("expected insufficient capacity error, got %s", err)
+	}
+	if IsErrorCode(err, ErrorCodeSSHKeyInUse) {
+		t.Errorf("did not expect SSH key in use error, got %s", err)
+	}
+	if IsNotFound(err) {
+		t.Errorf("did not expect not found error, got %s", err)
+	}
+	if !IsNotFound(CheckResponse(404, nil)) {
+		t.Errorf("expected not found error for HTTP 404")
+	}
+}
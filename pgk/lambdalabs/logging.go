@@ -0,0 +1,195 @@
+package lambdalabs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	// LogSubsystem is the tflog subsystem API calls are logged to. Its level can be set
+	// separately with the TF_LOG_PROVIDER_LAMBDALABS_API environment variable.
+	LogSubsystem = "lambdalabs_api"
+
+	redactedValue = "REDACTED"
+)
+
+// redactedHeaders are never logged.
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// redactedBodyFields are JSON object keys whose values are never logged, at any depth.
+var redactedBodyFields = map[string]bool{
+	"jupyter_token": true,
+	"private_key":   true,
+}
+
+// WithLogging logs every API call to the lambdalabs_api tflog subsystem of the request context:
+// the method, URL, status, latency and a request ID at DEBUG level, and the headers and bodies
+// at TRACE level. Credentials and secrets in bodies are always redacted. Bodies are only read
+// when LogLevel is TRACE, so that they are not buffered for nothing.
+//
+// It wraps the HttpRequestDoer configured so far, so it must come after WithHTTPClient, and
+// before WithRetry so that every attempt is logged.
+func WithLogging() ClientOption {
+	return func(c *Client) error {
+		c.Client = NewLoggingDoer(c.Client)
+		return nil
+	}
+}
+
+// NewLoggingDoer wraps doer, or a default http.Client if doer is nil, to log requests as
+// described by WithLogging.
+func NewLoggingDoer(doer HttpRequestDoer) HttpRequestDoer {
+	if doer == nil {
+		doer = &http.Client{}
+	}
+	return &loggingDoer{doer: doer, trace: LogLevel() == "TRACE"}
+}
+
+// LogLevel returns the level of the most specific of the TF_LOG_PROVIDER_LAMBDALABS_API,
+// TF_LOG_PROVIDER and TF_LOG environment variables that is set, e.g. DEBUG or TRACE, or ""
+// if none is set or logging is turned off. TF_LOG=JSON logs at TRACE level.
+func LogLevel() string {
+	for _, name := range []string{"TF_LOG_PROVIDER_LAMBDALABS_API", "TF_LOG_PROVIDER", "TF_LOG"} {
+		level := strings.ToUpper(strings.TrimSpace(os.Getenv(name)))
+		switch level {
+		case "":
+			continue
+		case "OFF":
+			return ""
+		case "JSON":
+			return "TRACE"
+		}
+		return level
+	}
+	return ""
+}
+
+type loggingDoer struct {
+	doer HttpRequestDoer
+	// trace Whether to log headers and bodies
+	trace bool
+}
+
+func (d *loggingDoer) Do(req *http.Request) (*http.Response, error) {
+	ctx := tflog.NewSubsystem(req.Context(), LogSubsystem,
+		tflog.WithLevelFromEnv("TF_LOG_PROVIDER_LAMBDALABS_API"),
+		tflog.WithRootFields(),
+	)
+	ctx = tflog.SubsystemSetField(ctx, LogSubsystem, "request_id", newRequestID())
+	ctx = tflog.SubsystemSetField(ctx, LogSubsystem, "method", req.Method)
+	ctx = tflog.SubsystemSetField(ctx, LogSubsystem, "url", req.URL.String())
+
+	if d.trace {
+		requestFields := map[string]interface{}{
+			"headers": redactHeaders(req.Header),
+		}
+		if req.Body != nil && req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				if data, err := io.ReadAll(body); err == nil {
+					requestFields["body"] = redactBody(data)
+				}
+				_ = body.Close()
+			}
+		}
+		tflog.SubsystemTrace(ctx, LogSubsystem, "Sending Lambda Labs API request", requestFields)
+	}
+
+	start := time.Now()
+	resp, err := d.doer.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		tflog.SubsystemDebug(ctx, LogSubsystem, "Lambda Labs API request failed", map[string]interface{}{
+			"latency": latency.String(),
+			"error":   err.Error(),
+		})
+		return resp, err
+	}
+
+	fields := map[string]interface{}{
+		"status":  resp.StatusCode,
+		"latency": latency.String(),
+	}
+	if serverRequestID := resp.Header.Get("X-Request-Id"); serverRequestID != "" {
+		fields["server_request_id"] = serverRequestID
+	}
+	tflog.SubsystemDebug(ctx, LogSubsystem, "Lambda Labs API request", fields)
+	if !d.trace {
+		return resp, nil
+	}
+
+	data, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if readErr != nil {
+		return resp, readErr
+	}
+	tflog.SubsystemTrace(ctx, LogSubsystem, "Received Lambda Labs API response", map[string]interface{}{
+		"headers": redactHeaders(resp.Header),
+		"body":    redactBody(data),
+	})
+	return resp, nil
+}
+
+// newRequestID returns a random ID to correlate the log lines of one API call.
+func newRequestID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// redactHeaders returns a copy of header without credentials.
+func redactHeaders(header http.Header) map[string]string {
+	redactedHeader := make(map[string]string, len(header))
+	for name := range header {
+		redactedHeader[name] = header.Get(name)
+	}
+	for _, name := range redactedHeaders {
+		if header.Get(name) != "" {
+			redactedHeader[http.CanonicalHeaderKey(name)] = redactedValue
+		}
+	}
+	return redactedHeader
+}
+
+// redactBody returns a JSON body with the values of redactedBodyFields replaced. Bodies that are
+// not JSON are returned as they are.
+func redactBody(data []byte) string {
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return string(data)
+	}
+	redactedBody, err := json.Marshal(redactValue(body))
+	if err != nil {
+		return string(data)
+	}
+	return string(redactedBody)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if redactedBodyFields[key] && nested != nil {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactValue(nested)
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactValue(nested)
+		}
+	}
+	return value
+}
@@ -0,0 +1,121 @@
+package lambdalabs
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultRequestsPerSecond is the rate limit documented at https://docs.lambdalabs.com/cloud/rate-limiting/
+const DefaultRequestsPerSecond = 1.0
+
+// RateLimiter is a token bucket that is safe for concurrent use. Tokens are added at a fixed
+// rate up to the burst size, and every request takes one token, waiting for it if needed.
+type RateLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+// NewRateLimiter creates a limiter allowing requestsPerSecond requests on average, and up to
+// burst requests at once. The bucket starts full.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:     requestsPerSecond,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		lastFill: time.Now(),
+	}
+}
+
+// Wait takes a token, blocking until one is available or ctx is done, and returns how long it
+// waited.
+func (l *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.lastFill).Seconds()*l.rate)
+	l.lastFill = now
+	// Reserve the token right away, so that concurrent waiters queue up behind each other
+	l.tokens--
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// Give the reserved token back
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return 0, ctx.Err()
+	case <-timer.C:
+		return wait, nil
+	}
+}
+
+// RateLimitOptions configures WithRateLimit.
+type RateLimitOptions struct {
+	// RequestsPerSecond Average number of requests per second. Zero or less disables rate limiting.
+	RequestsPerSecond float64
+
+	// Burst Number of requests that may be sent at once, defaults to 1
+	Burst int
+
+	// OnWait is called, if set, whenever a request had to wait for the limiter
+	OnWait func(ctx context.Context, req *http.Request, wait time.Duration)
+}
+
+// WithRateLimit makes every request, including retries, take a token from a limiter shared by
+// all users of the client.
+//
+// It wraps the HttpRequestDoer configured so far, so it must come after WithHTTPClient and
+// before WithRetry.
+func WithRateLimit(opts RateLimitOptions) ClientOption {
+	return func(c *Client) error {
+		if opts.RequestsPerSecond <= 0 {
+			return nil
+		}
+		c.Client = NewRateLimitedDoer(c.Client, NewRateLimiter(opts.RequestsPerSecond, opts.Burst), opts.OnWait)
+		return nil
+	}
+}
+
+// NewRateLimitedDoer wraps doer, or a default http.Client if doer is nil, so that every
+// request waits for limiter. onWait may be nil.
+func NewRateLimitedDoer(doer HttpRequestDoer, limiter *RateLimiter, onWait func(ctx context.Context, req *http.Request, wait time.Duration)) HttpRequestDoer {
+	if doer == nil {
+		doer = &http.Client{}
+	}
+	return &rateLimitedDoer{doer: doer, limiter: limiter, onWait: onWait}
+}
+
+type rateLimitedDoer struct {
+	doer    HttpRequestDoer
+	limiter *RateLimiter
+	onWait  func(ctx context.Context, req *http.Request, wait time.Duration)
+}
+
+func (d *rateLimitedDoer) Do(req *http.Request) (*http.Response, error) {
+	wait, err := d.limiter.Wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 && d.onWait != nil {
+		d.onWait(req.Context(), req, wait)
+	}
+	return d.doer.Do(req)
+}
@@ -0,0 +1,50 @@
+package lambdalabs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	limiter := NewRateLimiter(1, 3)
+
+	for i := 0; i < 3; i++ {
+		wait, err := limiter.Wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != 0 {
+			t.Errorf("expected request %d to be within the burst, waited %s", i, wait)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Wait(ctx); err == nil {
+		t.Errorf("expected the request after the burst to wait longer than the deadline")
+	}
+}
+
+func TestRateLimiterConcurrentWaiters(t *testing.T) {
+	limiter := NewRateLimiter(100, 1)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := limiter.Wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The first request takes the initial token, the other 4 wait 10ms each in turn
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("expected requests to be spread out over at least 40ms, took %s", elapsed)
+	}
+}
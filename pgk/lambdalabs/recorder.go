@@ -0,0 +1,206 @@
+package lambdalabs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RecorderMode selects whether a Recorder records API calls or replays them.
+type RecorderMode int
+
+const (
+	// RecorderModeReplay serves responses from the cassette without reaching the API.
+	RecorderModeReplay RecorderMode = iota
+	// RecorderModeRecord sends requests to the API and saves them with their responses to the cassette.
+	RecorderModeRecord
+)
+
+// Cassette API calls recorded by a Recorder.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction A recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest A recorded request, without its headers.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	// URL Path and query of the request, so that cassettes can be replayed against any host
+	URL  string `json:"url"`
+	Body string `json:"body,omitempty"`
+}
+
+// RecordedResponse A recorded response.
+type RecordedResponse struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// Recorder is an HttpRequestDoer that records API calls into a cassette file, or replays them
+// from it, so that tests can run without reaching the API. Credentials, private keys and
+// Jupyter tokens are scrubbed before they are recorded.
+//
+// In replay mode, every request is answered by the first unused interaction with the same
+// method, URL and body. Once all of them have been used the last one is served again, so that
+// polling and repeated refreshes do not need to be recorded an exact number of times.
+type Recorder struct {
+	path string
+	mode RecorderMode
+	doer HttpRequestDoer
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder creates a Recorder for the cassette file at path. In record mode, requests are sent
+// with doer, or a default http.Client if doer is nil, and the cassette is written by Stop.
+func NewRecorder(path string, mode RecorderMode, doer HttpRequestDoer) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, doer: doer}
+	if mode == RecorderModeRecord {
+		if r.doer == nil {
+			r.doer = &http.Client{}
+		}
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("unable to decode cassette %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// WithRecorder sends every request through r. It should be the first option, so that logging,
+// retries, rate limiting and caching behave the same as against the API.
+func WithRecorder(r *Recorder) ClientOption {
+	return WithHTTPClient(r)
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL.RequestURI(),
+	}
+	if len(body) > 0 {
+		recorded.Body = redactBody(body)
+	}
+
+	if r.mode == RecorderModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	resp, err := r.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	headers := make(map[string]string, len(resp.Header))
+	for name := range resp.Header {
+		headers[name] = resp.Header.Get(name)
+	}
+	for _, name := range redactedHeaders {
+		delete(headers, http.CanonicalHeaderKey(name))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    headers,
+			Body:       redactBody(data),
+		},
+	})
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for i, interaction := range r.cassette.Interactions {
+		if interaction.Request != recorded {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no interaction in cassette %s matches %s %s", r.path, recorded.Method, recorded.URL)
+	}
+	r.used[match] = true
+
+	response := r.cassette.Interactions[match].Response
+	header := make(http.Header, len(response.Headers))
+	for name, value := range response.Headers {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(response.Body))),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Stop writes the cassette in record mode. It does nothing in replay mode.
+func (r *Recorder) Stop() error {
+	if r.mode != RecorderModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("unable to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("unable to write cassette: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package lambdalabs
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetryMaxAttempts is the default number of attempts, including the first one.
+	DefaultRetryMaxAttempts = 5
+	// DefaultRetryMinBackoff is the default wait before the first retry.
+	DefaultRetryMinBackoff = time.Second
+	// DefaultRetryMaxBackoff is the default upper bound of the wait between attempts.
+	DefaultRetryMaxBackoff = 30 * time.Second
+)
+
+// RetryOptions configures how requests are retried by WithRetry.
+// Zero values are replaced with their defaults.
+type RetryOptions struct {
+	// MaxAttempts Number of attempts, including the first one. 1 disables retries.
+	MaxAttempts int
+
+	// MinBackoff Wait before the first retry, doubled on every following retry
+	MinBackoff time.Duration
+
+	// MaxBackoff Upper bound of the wait between attempts, including waits requested through Retry-After
+	MaxBackoff time.Duration
+}
+
+// WithRetry retries requests that failed with a network error, 429 Too Many Requests or a 5xx
+// status code, using exponential back-off with jitter and honouring Retry-After. Requests that
+// are not idempotent, such as launching instances, are only retried on 429, since the API
+// rejected them before acting on them.
+//
+// It wraps the HttpRequestDoer configured so far, so it must come after WithHTTPClient.
+func WithRetry(opts RetryOptions) ClientOption {
+	return func(c *Client) error {
+		c.Client = NewRetryingDoer(c.Client, opts)
+		return nil
+	}
+}
+
+// NewRetryingDoer wraps doer, or a default http.Client if doer is nil, to retry requests
+// as described by WithRetry.
+func NewRetryingDoer(doer HttpRequestDoer, opts RetryOptions) HttpRequestDoer {
+	if doer == nil {
+		doer = &http.Client{}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultRetryMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if opts.MinBackoff > opts.MaxBackoff {
+		opts.MinBackoff = opts.MaxBackoff
+	}
+	return &retryingDoer{doer: doer, opts: opts}
+}
+
+type retryingDoer struct {
+	doer HttpRequestDoer
+	opts RetryOptions
+}
+
+func (d *retryingDoer) Do(req *http.Request) (*http.Response, error) {
+	idempotent := isIdempotent(req)
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := d.doer.Do(req)
+
+		retryable := false
+		switch {
+		case err != nil:
+			retryable = idempotent && req.Context().Err() == nil
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retryable = true
+		case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+			retryable = idempotent
+		}
+		if !retryable || attempt >= d.opts.MaxAttempts || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		wait := d.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = retryAfter
+				if wait > d.opts.MaxBackoff {
+					wait = d.opts.MaxBackoff
+				}
+			}
+			// Drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait before the given retry, growing exponentially from MinBackoff up to
+// MaxBackoff. Half of the wait is random, so that parallel operations spread out.
+func (d *retryingDoer) backoff(attempt int) time.Duration {
+	wait := d.opts.MinBackoff
+	for i := 1; i < attempt && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.opts.MaxBackoff {
+		wait = d.opts.MaxBackoff
+	}
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isIdempotent reports whether sending req again cannot have additional effects. Terminating
+// and restarting instances are POST requests, but repeating them has no further effect.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return strings.HasSuffix(req.URL.Path, "/instance-operations/terminate") ||
+			strings.HasSuffix(req.URL.Path, "/instance-operations/restart")
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header, given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := date.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
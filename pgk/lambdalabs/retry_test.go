@@ -0,0 +1,144 @@
+package lambdalabs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRetryClient(t *testing.T, handler http.HandlerFunc) *ClientWithResponses {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewAuthenticatedClient(server.URL, "secret", WithRetry(RetryOptions{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	var calls int32
+	client := newTestRetryClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"data": []}`)
+	})
+
+	response, err := client.ListInstancesWithResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode() != http.StatusOK {
+		t.Errorf("expected HTTP 200, got %d", response.StatusCode())
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	client := newTestRetryClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	response, err := client.ListInstancesWithResponse(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("expected HTTP 503, got %d", response.StatusCode())
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryDoesNotResendLaunch(t *testing.T) {
+	var calls int32
+	client := newTestRetryClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	_, err := client.LaunchInstanceWithResponse(context.Background(), LaunchInstanceJSONRequestBody{
+		InstanceTypeName: "gpu_1x_a10",
+		RegionName:       "us-east-1",
+		SshKeyNames:      []string{"key"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("expected launch to be sent once, got %d calls", calls)
+	}
+}
+
+func TestRetryResendsLaunchOnTooManyRequests(t *testing.T) {
+	var calls int32
+	client := newTestRetryClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "gpu_1x_a10") {
+			t.Errorf("request body was not replayed, got %q", body)
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"data": {"instance_ids": ["0920582c7ff041399e34823a0be62549"]}}`)
+	})
+
+	response, err := client.LaunchInstanceWithResponse(context.Background(), LaunchInstanceJSONRequestBody{
+		InstanceTypeName: "gpu_1x_a10",
+		RegionName:       "us-east-1",
+		SshKeyNames:      []string{"key"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.JSON200 == nil {
+		t.Fatalf("expected a launched instance, got HTTP %d: %s", response.StatusCode(), response.Body)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"7":                             7 * time.Second,
+		"Sun, 01 Oct 2023 12:00:30 GMT": 30 * time.Second,
+		"Sun, 01 Oct 2023 11:00:00 GMT": 0,
+	}
+	for value, expected := range tests {
+		actual, ok := parseRetryAfter(value, now)
+		if !ok || actual != expected {
+			t.Errorf("parseRetryAfter(%q) = %s, %t, expected %s", value, actual, ok, expected)
+		}
+	}
+
+	for _, value := range []string{"", "-1", "soon"} {
+		if _, ok := parseRetryAfter(value, now); ok {
+			t.Errorf("expected parseRetryAfter(%q) to fail", value)
+		}
+	}
+}
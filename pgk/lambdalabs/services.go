@@ -0,0 +1,271 @@
+package lambdalabs
+
+import (
+	"context"
+	"fmt"
+)
+
+// InstancesService manages instances.
+type InstancesService interface {
+	// List returns every instance of the account, including terminated ones that are still listed.
+	List(ctx context.Context) ([]Instance, error)
+	// Get returns a single instance. Unknown IDs return an error for which IsNotFound is true.
+	Get(ctx context.Context, id string) (Instance, error)
+	// FindByName returns the only instance with the given name that is not terminating or terminated.
+	FindByName(ctx context.Context, name string) (Instance, error)
+	// Launch launches instances and returns their IDs, which may be fewer than the requested quantity.
+	Launch(ctx context.Context, body LaunchInstanceJSONRequestBody) ([]string, error)
+	// Terminate terminates instances and returns those the API reported as terminated.
+	Terminate(ctx context.Context, ids []string) ([]Instance, error)
+	// Restart restarts instances and returns those the API reported as restarted.
+	Restart(ctx context.Context, ids []string) ([]Instance, error)
+}
+
+// InstanceTypesService lists instance types.
+type InstanceTypesService interface {
+	// List returns every instance type by name, along with the regions where it has capacity.
+	List(ctx context.Context) (map[string]InstanceTypeAvailability, error)
+}
+
+// FileSystemsService lists file systems.
+type FileSystemsService interface {
+	// List returns every file system of the account.
+	List(ctx context.Context) ([]FileSystem, error)
+}
+
+// SSHKeysService manages SSH keys.
+type SSHKeysService interface {
+	// List returns every SSH key of the account.
+	List(ctx context.Context) ([]SshKey, error)
+	// Get returns a single SSH key. Unknown IDs return an error for which IsNotFound is true.
+	Get(ctx context.Context, id string) (SshKey, error)
+	// FindByName returns the SSH key with the given name.
+	FindByName(ctx context.Context, name string) (SshKey, error)
+	// Add adds an SSH key, or generates a new key pair when no public key is given.
+	Add(ctx context.Context, body AddSSHKeyJSONRequestBody) (SshKey, error)
+	// Delete deletes an SSH key.
+	Delete(ctx context.Context, id string) error
+}
+
+// InstanceTypeAvailability An instance type and the regions where it currently has capacity.
+type InstanceTypeAvailability struct {
+	// InstanceType Hardware configuration and pricing of an instance type
+	InstanceType InstanceType `json:"instance_type"`
+
+	// RegionsWithCapacityAvailable List of regions, if any, that have this instance type available
+	RegionsWithCapacityAvailable []Region `json:"regions_with_capacity_available"`
+}
+
+// Services bundles the services of the Lambda Labs API.
+type Services struct {
+	Instances     InstancesService
+	InstanceTypes InstanceTypesService
+	FileSystems   FileSystemsService
+	SSHKeys       SSHKeysService
+}
+
+// NewServices creates the services of the Lambda Labs API on top of client.
+func NewServices(client ClientWithResponsesInterface) *Services {
+	return &Services{
+		Instances:     &instancesService{client: client},
+		InstanceTypes: &instanceTypesService{client: client},
+		FileSystems:   &fileSystemsService{client: client},
+		SSHKeys:       &sshKeysService{client: client},
+	}
+}
+
+// checkResult turns a non-2xx response, or a 2xx response without the expected body,
+// into an error.
+func checkResult(statusCode int, body []byte, decoded bool) error {
+	if err := CheckResponse(statusCode, body); err != nil {
+		return err
+	}
+	if !decoded {
+		return fmt.Errorf("unexpected response from the Lambda Labs API (HTTP %d): %s", statusCode, body)
+	}
+	return nil
+}
+
+type instancesService struct {
+	client ClientWithResponsesInterface
+}
+
+func (s *instancesService) List(ctx context.Context) ([]Instance, error) {
+	response, err := s.client.ListInstancesWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return response.JSON200.Data, nil
+}
+
+func (s *instancesService) Get(ctx context.Context, id string) (Instance, error) {
+	response, err := s.client.GetInstanceWithResponse(ctx, id)
+	if err != nil {
+		return Instance{}, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return Instance{}, err
+	}
+	return response.JSON200.Data, nil
+}
+
+func (s *instancesService) FindByName(ctx context.Context, name string) (Instance, error) {
+	instances, err := s.List(ctx)
+	if err != nil {
+		return Instance{}, err
+	}
+
+	matches := make([]Instance, 0)
+	for _, instance := range instances {
+		if instance.Name == nil || *instance.Name != name {
+			continue
+		}
+		if instance.Status == InstanceStatusTerminating || instance.Status == InstanceStatusTerminated {
+			continue
+		}
+		matches = append(matches, instance)
+	}
+
+	switch len(matches) {
+	case 0:
+		return Instance{}, fmt.Errorf("no instance named %q: %w", name, ErrNotFound)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, 0, len(matches))
+	for _, instance := range matches {
+		ids = append(ids, instance.Id)
+	}
+	return Instance{}, fmt.Errorf("%d instances are named %q (%v), use an instance ID instead", len(matches), name, ids)
+}
+
+func (s *instancesService) Launch(ctx context.Context, body LaunchInstanceJSONRequestBody) ([]string, error) {
+	response, err := s.client.LaunchInstanceWithResponse(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return response.JSON200.Data.InstanceIds, nil
+}
+
+func (s *instancesService) Terminate(ctx context.Context, ids []string) ([]Instance, error) {
+	response, err := s.client.TerminateInstanceWithResponse(ctx, TerminateInstanceJSONRequestBody{InstanceIds: ids})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return response.JSON200.Data.TerminatedInstances, nil
+}
+
+func (s *instancesService) Restart(ctx context.Context, ids []string) ([]Instance, error) {
+	response, err := s.client.RestartInstanceWithResponse(ctx, RestartInstanceJSONRequestBody{InstanceIds: ids})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return response.JSON200.Data.RestartedInstances, nil
+}
+
+type instanceTypesService struct {
+	client ClientWithResponsesInterface
+}
+
+func (s *instanceTypesService) List(ctx context.Context) (map[string]InstanceTypeAvailability, error) {
+	response, err := s.client.InstanceTypesWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	instanceTypes := make(map[string]InstanceTypeAvailability, len(response.JSON200.Data))
+	for name, availability := range response.JSON200.Data {
+		instanceTypes[name] = InstanceTypeAvailability(availability)
+	}
+	return instanceTypes, nil
+}
+
+type fileSystemsService struct {
+	client ClientWithResponsesInterface
+}
+
+func (s *fileSystemsService) List(ctx context.Context) ([]FileSystem, error) {
+	response, err := s.client.ListFileSystemsWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return response.JSON200.Data, nil
+}
+
+type sshKeysService struct {
+	client ClientWithResponsesInterface
+}
+
+func (s *sshKeysService) List(ctx context.Context) ([]SshKey, error) {
+	response, err := s.client.ListSSHKeysWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return response.JSON200.Data, nil
+}
+
+// Get lists the SSH keys, since the API cannot get a single one.
+func (s *sshKeysService) Get(ctx context.Context, id string) (SshKey, error) {
+	sshKeys, err := s.List(ctx)
+	if err != nil {
+		return SshKey{}, err
+	}
+	for _, sshKey := range sshKeys {
+		if sshKey.Id == id {
+			return sshKey, nil
+		}
+	}
+	return SshKey{}, fmt.Errorf("no SSH key with ID %q: %w", id, ErrNotFound)
+}
+
+func (s *sshKeysService) FindByName(ctx context.Context, name string) (SshKey, error) {
+	sshKeys, err := s.List(ctx)
+	if err != nil {
+		return SshKey{}, err
+	}
+	for _, sshKey := range sshKeys {
+		if sshKey.Name == name {
+			return sshKey, nil
+		}
+	}
+	return SshKey{}, fmt.Errorf("no SSH key named %q: %w", name, ErrNotFound)
+}
+
+func (s *sshKeysService) Add(ctx context.Context, body AddSSHKeyJSONRequestBody) (SshKey, error) {
+	response, err := s.client.AddSSHKeyWithResponse(ctx, body)
+	if err != nil {
+		return SshKey{}, err
+	}
+	if err := checkResult(response.StatusCode(), response.Body, response.JSON200 != nil); err != nil {
+		return SshKey{}, err
+	}
+	return response.JSON200.Data, nil
+}
+
+func (s *sshKeysService) Delete(ctx context.Context, id string) error {
+	response, err := s.client.DeleteSSHKeyWithResponse(ctx, id)
+	if err != nil {
+		return err
+	}
+	return CheckResponse(response.StatusCode(), response.Body)
+}
@@ -0,0 +1,128 @@
+package lambdalabs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestServices(t *testing.T, routes map[string]string) *Services {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"error": {"code": "global/object-does-not-exist", "message": "Not found."}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewAuthenticatedClient(server.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServices(client)
+}
+
+func TestInstancesServiceFindByName(t *testing.T) {
+	services := newTestServices(t, map[string]string{
+		"GET /instances": `{"data": [
+			{"id": "1", "name": "trainer", "status": "terminated", "ssh_key_names": [], "file_system_names": []},
+			{"id": "2", "name": "trainer", "status": "active", "ssh_key_names": [], "file_system_names": []},
+			{"id": "3", "name": "worker", "status": "active", "ssh_key_names": [], "file_system_names": []},
+			{"id": "4", "name": "worker", "status": "booting", "ssh_key_names": [], "file_system_names": []}
+		]}`,
+	})
+	ctx := context.Background()
+
+	instance, err := services.Instances.FindByName(ctx, "trainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Id != "2" {
+		t.Errorf("expected the live instance 2, got %s", instance.Id)
+	}
+
+	if _, err := services.Instances.FindByName(ctx, "worker"); err == nil || IsNotFound(err) {
+		t.Errorf("expected an ambiguous name error, got %v", err)
+	}
+	if _, err := services.Instances.FindByName(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestInstancesServiceGetNotFound(t *testing.T) {
+	services := newTestServices(t, map[string]string{})
+
+	_, err := services.Instances.Get(context.Background(), "0920582c7ff041399e34823a0be62549")
+	if !IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if !IsErrorCode(err, ErrorCodeObjectDoesNotExist) {
+		t.Errorf("expected error code %s, got %v", ErrorCodeObjectDoesNotExist, err)
+	}
+}
+
+func TestInstanceTypesServiceList(t *testing.T) {
+	services := newTestServices(t, map[string]string{
+		"GET /instance-types": `{"data": {"gpu_1x_a10": {
+			"instance_type": {"name": "gpu_1x_a10", "description": "1x A10 (24 GB PCIe)", "price_cents_per_hour": 60,
+				"specs": {"vcpus": 30, "memory_gib": 200, "storage_gib": 1400}},
+			"regions_with_capacity_available": [{"name": "us-east-1", "description": "Virginia, USA"}]
+		}}}`,
+	})
+
+	instanceTypes, err := services.InstanceTypes.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	availability, ok := instanceTypes["gpu_1x_a10"]
+	if !ok {
+		t.Fatalf("expected instance type gpu_1x_a10, got %v", instanceTypes)
+	}
+	if len(availability.RegionsWithCapacityAvailable) != 1 || availability.RegionsWithCapacityAvailable[0].Name != "us-east-1" {
+		t.Errorf("unexpected regions %v", availability.RegionsWithCapacityAvailable)
+	}
+}
+
+func TestSSHKeysServiceGet(t *testing.T) {
+	services := newTestServices(t, map[string]string{
+		"GET /ssh-keys": `{"data": [{"id": "ddf9a910ceb744a0bb95242cbba6cb50", "name": "laptop", "public_key": "ssh-ed25519 AAAA"}]}`,
+	})
+	ctx := context.Background()
+
+	sshKey, err := services.SSHKeys.Get(ctx, "ddf9a910ceb744a0bb95242cbba6cb50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sshKey.Name != "laptop" {
+		t.Errorf("unexpected SSH key %v", sshKey)
+	}
+	if _, err := services.SSHKeys.Get(ctx, "laptop"); !IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestSSHKeysServiceFindByName(t *testing.T) {
+	services := newTestServices(t, map[string]string{
+		"GET /ssh-keys": `{"data": [{"id": "ddf9a910ceb744a0bb95242cbba6cb50", "name": "laptop", "public_key": "ssh-ed25519 AAAA"}]}`,
+	})
+	ctx := context.Background()
+
+	sshKey, err := services.SSHKeys.FindByName(ctx, "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sshKey.Id != "ddf9a910ceb744a0bb95242cbba6cb50" {
+		t.Errorf("unexpected SSH key %v", sshKey)
+	}
+	if _, err := services.SSHKeys.FindByName(ctx, "desktop"); !IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package lambdalabs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTPClientOptions configures the HTTP client used to reach the API, e.g. through a
+// TLS-intercepting proxy.
+type HTTPClientOptions struct {
+	// CACertFile Path to a PEM file of certificate authorities to trust in addition to the system ones
+	CACertFile string
+
+	// CACertPEM PEM encoded certificate authorities to trust in addition to the system ones
+	CACertPEM string
+
+	// ProxyURL URL of the proxy to send requests through. Empty to use the HTTPS_PROXY and NO_PROXY
+	// environment variables
+	ProxyURL string
+
+	// InsecureSkipVerify Skip verification of the server certificate, for local stand-ins of the API only
+	InsecureSkipVerify bool
+
+	// Timeout Time limit of a single request, including reading the response body. Zero for no limit
+	Timeout time.Duration
+}
+
+// NewHTTPClient creates an *http.Client for use with WithHTTPClient.
+func NewHTTPClient(opts HTTPClientOptions) (*http.Client, error) {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unexpected default HTTP transport %T", http.DefaultTransport)
+	}
+	transport = transport.Clone()
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if opts.CACertFile != "" || opts.CACertPEM != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if opts.CACertFile != "" {
+			pem, err := os.ReadFile(opts.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read CA certificates: %w", err)
+			}
+			if !rootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no PEM encoded certificates found in %s", opts.CACertFile)
+			}
+		}
+		if opts.CACertPEM != "" && !rootCAs.AppendCertsFromPEM([]byte(opts.CACertPEM)) {
+			return nil, fmt.Errorf("no PEM encoded certificates found in the CA certificate PEM")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse proxy URL: %w", err)
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("proxy URL %q must have a scheme and a host, e.g. http://proxy:3128", opts.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+	}, nil
+}
@@ -0,0 +1,110 @@
+package lambdalabs
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTLSTestServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	caCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return server, string(caCertPEM)
+}
+
+func TestNewHTTPClientTLS(t *testing.T) {
+	server, caCertPEM := newTLSTestServer(t)
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caCertFile, []byte(caCertPEM), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		opts        HTTPClientOptions
+		expectError bool
+	}{
+		"untrusted":            {opts: HTTPClientOptions{}, expectError: true},
+		"ca_cert_pem":          {opts: HTTPClientOptions{CACertPEM: caCertPEM}},
+		"ca_cert_file":         {opts: HTTPClientOptions{CACertFile: caCertFile}},
+		"insecure_skip_verify": {opts: HTTPClientOptions{InsecureSkipVerify: true}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewHTTPClient(c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := client.Get(server.URL)
+			if c.expectError {
+				if err == nil {
+					_ = response.Body.Close()
+					t.Fatal("expected a certificate verification error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = response.Body.Close()
+		})
+	}
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.String()
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(HTTPClientOptions{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.Get("http://cloud.lambdalabs.example/api/v1/instances")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	if requested := <-proxied; requested != "http://cloud.lambdalabs.example/api/v1/instances" {
+		t.Errorf("expected the request to go through the proxy, proxy got %s", requested)
+	}
+}
+
+func TestNewHTTPClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(HTTPClientOptions{Timeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response, err := client.Get(server.URL); err == nil {
+		_ = response.Body.Close()
+		t.Error("expected the request to time out")
+	}
+}
+
+func TestNewHTTPClientInvalidOptions(t *testing.T) {
+	for name, opts := range map[string]HTTPClientOptions{
+		"missing ca_cert_file": {CACertFile: filepath.Join(t.TempDir(), "missing.pem")},
+		"invalid ca_cert_pem":  {CACertPEM: "not a certificate"},
+		"relative proxy_url":   {ProxyURL: "proxy:3128"},
+	} {
+		if _, err := NewHTTPClient(opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}